@@ -0,0 +1,226 @@
+package slog
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Access log formats of AccessLogHandler
+const (
+	FieldsAccessLogFormat   = "fields"
+	CombinedAccessLogFormat = "combined"
+)
+
+// AccessLogHandler is an http middleware creating a session for each request
+// with request_id, method, path and remote_addr fields, the session is stored
+// in the request context and can be got with FromContext, and ended after the
+// request is logged. Completed requests are logged with status, bytes and
+// latency_ms fields, or in Apache combined log
+// format as message if Format is "combined". Requests whose handlers panic are
+// logged with the status already written, or 500, and the panic and stack
+// fields before the panic continues. The level is chosen by StatusLevels
+// keyed by status class such as "5xx", which defaults to error for 5xx, warn for
+// 4xx and info for the others.
+type AccessLogHandler struct {
+	Handler         http.Handler
+	Session         Session
+	RequestIDHeader string
+	TraceContext    bool
+	Format          string
+	StatusLevels    map[string]string
+}
+
+// AccessLog wrap handler with the default AccessLogHandler
+func AccessLog(handler http.Handler) http.Handler {
+	return &AccessLogHandler{Handler: handler}
+}
+
+type accessLogResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (writer *accessLogResponseWriter) WriteHeader(status int) {
+	if writer.status == 0 {
+		writer.status = status
+	}
+	writer.ResponseWriter.WriteHeader(status)
+}
+
+func (writer *accessLogResponseWriter) Write(content []byte) (int, error) {
+	if writer.status == 0 {
+		writer.status = http.StatusOK
+	}
+	n, err := writer.ResponseWriter.Write(content)
+	writer.bytes += n
+	return n, err
+}
+
+func (writer *accessLogResponseWriter) Flush() {
+	if flusher, ok := writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (writer *accessLogResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := writer.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("hijack not supported")
+}
+
+func (writer *accessLogResponseWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+func (handler *AccessLogHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	start := time.Now()
+	session := handler.newSession(request)
+	request = request.WithContext(NewContext(request.Context(), session))
+	recorder := &accessLogResponseWriter{ResponseWriter: writer}
+	// 访问日志写入后结束会话
+	defer session.End()
+	defer func() {
+		// 处理过程中panic时, 未写入状态的按500记录, 附带panic处的调用栈后继续抛出
+		if err := recover(); err != nil {
+			if recorder.status == 0 {
+				recorder.status = http.StatusInternalServerError
+			}
+			handler.log(session, request, recorder, start, Fields{"panic": fmt.Sprint(err), stackKey: string(debug.Stack())})
+			panic(err)
+		}
+		handler.log(session, request, recorder, start, nil)
+	}()
+	handler.Handler.ServeHTTP(recorder, request)
+}
+
+func (handler *AccessLogHandler) newSession(request *http.Request) Session {
+	session := handler.Session
+	if session == nil {
+		session = NewSession()
+	}
+	if handler.TraceContext {
+		session = session.WithTraceContext(request)
+	} else {
+		session = session.Derive()
+	}
+	header := handler.RequestIDHeader
+	if header == "" {
+		header = "X-Request-ID"
+	}
+	requestID := request.Header.Get(header)
+	if requestID == "" {
+		requestID = randomHex(16)
+	}
+	return session.
+		WithField("request_id", requestID).
+		WithField("method", request.Method).
+		WithField("path", request.URL.Path).
+		WithField("remote_addr", request.RemoteAddr)
+}
+
+func (handler *AccessLogHandler) level(status int) string {
+	if level := handler.StatusLevels[strconv.Itoa(status/100)+"xx"]; level != "" {
+		return level
+	}
+	switch {
+	case status >= 500:
+		return errorLevel
+	case status >= 400:
+		return warnLevel
+	}
+	return infoLevel
+}
+
+func (handler *AccessLogHandler) log(session Session, request *http.Request, recorder *accessLogResponseWriter, start time.Time, fields Fields) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	latency := time.Since(start)
+	event := session.EventSkip(1).WithFields(fields)
+	if handler.Format == CombinedAccessLogFormat {
+		event.Log(handler.level(recorder.status), combinedLogLine(request, recorder.status, recorder.bytes, start))
+		return
+	}
+	event.WithFields(Fields{
+		"status":     recorder.status,
+		"bytes":      recorder.bytes,
+		"latency_ms": float64(latency) / float64(time.Millisecond),
+	}).Log(handler.level(recorder.status), "request completed")
+}
+
+// combinedLogLine format request in Apache combined log format
+func combinedLogLine(request *http.Request, status, bytes int, start time.Time) string {
+	host := request.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	user := "-"
+	if request.URL.User != nil && request.URL.User.Username() != "" {
+		user = escapeLogItem(request.URL.User.Username())
+	} else if username, _, ok := request.BasicAuth(); ok && username != "" {
+		user = escapeLogItem(username)
+	}
+	size := "-"
+	if bytes > 0 {
+		size = strconv.Itoa(bytes)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s\" %d %s \"%s\" \"%s\"",
+		escapeLogItem(host), user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		escapeLogItem(request.Method+" "+request.URL.RequestURI()+" "+request.Proto), status, size,
+		escapeCombined(request.Referer()), escapeCombined(request.UserAgent()))
+}
+
+func escapeCombined(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return escapeLogItem(value)
+}
+
+// escapeLogItem escape value as Apache does for access logs, quotes and
+// backslashes are escaped with backslashes, control characters and bytes
+// beyond ASCII are written as \n or \xhh, so that values cannot break lines
+// or quoted fields
+func escapeLogItem(value string) string {
+	i := 0
+	for i < len(value) && value[i] >= 0x20 && value[i] < 0x7f && value[i] != '"' && value[i] != '\\' {
+		i++
+	}
+	if i == len(value) {
+		return value
+	}
+	var builder strings.Builder
+	builder.WriteString(value[:i])
+	for ; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"', '\\':
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		case '\b':
+			builder.WriteString(`\b`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\r':
+			builder.WriteString(`\r`)
+		case '\t':
+			builder.WriteString(`\t`)
+		case '\v':
+			builder.WriteString(`\v`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&builder, `\x%02x`, c)
+			} else {
+				builder.WriteByte(c)
+			}
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,129 @@
+package slog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogHandler(t *testing.T) {
+	receiver := new(receiveHandler)
+	handlers = map[string][]Handler{
+		infoLevel: []Handler{receiver},
+		warnLevel: []Handler{receiver},
+	}
+	defer func() { handlers = nil }()
+	var requestSession Session
+	handler := AccessLog(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		requestSession = FromContext(request.Context())
+		if request.URL.Path == "/missing" {
+			http.NotFound(writer, request)
+			return
+		}
+		writer.Write([]byte("hello"))
+	}))
+	request := httptest.NewRequest("GET", "/hello", nil)
+	request.Header.Set("X-Request-ID", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if requestSession["request_id"] != "abc" || requestSession["method"] != "GET" || requestSession["path"] != "/hello" ||
+		requestSession["remote_addr"] != request.RemoteAddr {
+		t.Error("unexpected session:", requestSession)
+	}
+	if order := sessionOrder(sessionID(requestSession)); order != nil {
+		t.Error("session not ended:", order)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	if len(receiver.events) != 2 {
+		t.Error("unexpected events:", receiver.events)
+		return
+	}
+	if event := receiver.events[0]; event.Level != infoLevel || event.Fields["status"] != 200 || event.Fields["bytes"] != 5 ||
+		event.Fields["latency_ms"] == nil || event.Session["request_id"] != "abc" {
+		t.Error("unexpected event:", event)
+	}
+	if event := receiver.events[1]; event.Level != warnLevel || event.Fields["status"] != 404 {
+		t.Error("unexpected event:", event)
+	}
+}
+
+func TestAccessLogHandlerCombined(t *testing.T) {
+	receiver := new(receiveHandler)
+	handlers = map[string][]Handler{
+		errorLevel: []Handler{receiver},
+		"debug":    []Handler{receiver},
+	}
+	defer func() { handlers = nil }()
+	handler := &AccessLogHandler{
+		Handler: http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			writer.WriteHeader(http.StatusNoContent)
+		}),
+		Format:       CombinedAccessLogFormat,
+		StatusLevels: map[string]string{"2xx": debugLevel},
+		TraceContext: true,
+	}
+	request := httptest.NewRequest("GET", "/hello?foo=bar", nil)
+	request.SetBasicAuth("steve", "secret")
+	request.Header.Set("User-Agent", "test \"agent\"")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if len(receiver.events) != 1 {
+		t.Error("unexpected events:", receiver.events)
+		return
+	}
+	event := receiver.events[0]
+	pattern := regexp.MustCompile(`^192\.0\.2\.1 - steve \[[^\]]+\] "GET /hello\?foo=bar HTTP/1\.1" 204 - "-" "test \\"agent\\""$`)
+	if event.Level != debugLevel || !pattern.MatchString(event.Message) || event.Session[TraceIDKey] == nil {
+		t.Error("unexpected event:", event.Level, event.Message, event.Session)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("miss panic")
+		} else if len(receiver.events) != 2 || receiver.events[1].Level != errorLevel {
+			t.Error("unexpected events:", receiver.events)
+		} else if event := receiver.events[1]; !strings.Contains(event.Message, `"GET / HTTP/1.1" 500 -`) ||
+			event.Fields["panic"] != "test" || !strings.Contains(event.Fields["stack"].(string), "panic(") {
+			t.Error("unexpected event:", event.Message, event.Fields)
+		}
+	}()
+	handler.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("test") })
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestAccessLogHandlerPanicStatus(t *testing.T) {
+	receiver := new(receiveHandler)
+	handlers = map[string][]Handler{warnLevel: []Handler{receiver}}
+	defer func() { handlers = nil }()
+	handler := AccessLog(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusBadRequest)
+		panic(http.ErrAbortHandler)
+	}))
+	func() {
+		defer func() {
+			if err := recover(); err != http.ErrAbortHandler {
+				t.Error("unexpected panic:", err)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	if len(receiver.events) != 1 || receiver.events[0].Fields["status"] != http.StatusBadRequest ||
+		receiver.events[0].Fields["stack"] == nil {
+		t.Error("unexpected events:", receiver.events)
+	}
+}
+
+func TestEscapeLogItem(t *testing.T) {
+	cases := map[string]string{
+		"":                       "-",
+		"curl/7.0":               "curl/7.0",
+		"a \"b\" \\c":            `a \"b\" \\c`,
+		"x\n127.0.0.1 - - [...]": `x\n127.0.0.1 - - [...]`,
+		"\r\t\x00\x1b\x7f":       `\r\t\x00\x1b\x7f`,
+		"日":                      `\xe6\x97\xa5`,
+	}
+	for value, expected := range cases {
+		if escaped := escapeCombined(value); escaped != expected {
+			t.Errorf("escape %q: expect %s, got %s", value, expected, escaped)
+		}
+	}
+}
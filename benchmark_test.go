@@ -0,0 +1,209 @@
+package slog
+
+import (
+	"testing"
+	"time"
+)
+
+type discardWriter struct{}
+
+func (discardWriter) Write([]byte) error {
+	return nil
+}
+
+func (discardWriter) Transient() bool {
+	return true
+}
+
+type discardHandler struct{}
+
+func (discardHandler) Handle(*Event) {}
+
+func benchmarkSession() Session {
+	return NewSession().WithFields(Fields{
+		"request_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"user":       "alice",
+		"attempt":    3,
+	})
+}
+
+func benchmarkHandler(b *testing.B, handler Handler) {
+	previous := SetHandlers(map[string][]Handler{infoLevel: {handler}})
+	defer SetHandlers(previous)
+	session := benchmarkSession()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		session.Info("request completed")
+	}
+}
+
+func BenchmarkDisabledLevel(b *testing.B) {
+	previous := SetHandlers(map[string][]Handler{infoLevel: {discardHandler{}}})
+	defer SetHandlers(previous)
+	session := benchmarkSession()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		session.Debugf("request %s completed", "GET /")
+	}
+}
+
+func BenchmarkDisabledEventAt(b *testing.B) {
+	previous := SetHandlers(map[string][]Handler{infoLevel: {discardHandler{}}})
+	defer SetHandlers(previous)
+	session := benchmarkSession()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		session.EventAt(debugLevel).WithField("status", 200).Print("request completed")
+	}
+}
+
+func BenchmarkEventAt(b *testing.B) {
+	previous := SetHandlers(map[string][]Handler{infoLevel: {discardHandler{}}})
+	defer SetHandlers(previous)
+	session := benchmarkSession()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		session.EventAt(infoLevel).WithField("status", 200).Print("request completed")
+	}
+}
+
+func BenchmarkDiscardHandler(b *testing.B) {
+	benchmarkHandler(b, discardHandler{})
+}
+
+func BenchmarkEventFieldify(b *testing.B) {
+	event := newEvent(1, benchmarkSession())
+	event.Level = infoLevel
+	event.Message = "request completed"
+	event.Fields["status"] = 200
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event.Fieldify(time.RFC3339)
+	}
+}
+
+func BenchmarkPlainTextFormatter(b *testing.B) {
+	formatter := &PlainTextFormatter{
+		EventFormat: "%(level|s) [%(timestamp|s)] %(message|s) [%(.all_fields_space_seperated_text|s)]",
+	}
+	event := newEvent(1, benchmarkSession())
+	event.Level = infoLevel
+	event.Message = "request completed"
+	event.Fields["status"] = 200
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		formatter.FormatEvent(event)
+	}
+}
+
+func BenchmarkJsonHandler(b *testing.B) {
+	benchmarkHandler(b, &JsonHandler{Writer: discardWriter{}})
+}
+
+func BenchmarkPlainTextHandler(b *testing.B) {
+	benchmarkHandler(b, &PlainTextHandler{
+		Formatter: &PlainTextFormatter{
+			EventFormat: "%(level|s) [%(timestamp|s)] %(message|s) [%(.all_fields_space_seperated_text|s)]",
+		},
+		Writer: discardWriter{},
+	})
+}
+
+func BenchmarkFilterHandler(b *testing.B) {
+	benchmarkHandler(b, &FilterHandler{
+		Expression: `level >= "info" && session.user == "alice"`,
+		Handler:    &JsonHandler{Writer: discardWriter{}},
+	})
+}
+
+func BenchmarkSamplingHandler(b *testing.B) {
+	benchmarkHandler(b, &SamplingHandler{
+		Handler:    &JsonHandler{Writer: discardWriter{}},
+		Tick:       time.Second,
+		First:      10,
+		Thereafter: 100,
+	})
+}
+
+func BenchmarkRateLimitHandler(b *testing.B) {
+	benchmarkHandler(b, &RateLimitHandler{
+		Handler:     &JsonHandler{Writer: discardWriter{}},
+		LevelLimits: map[string]RateLimit{infoLevel: {Rate: 1000, Burst: 1000}},
+	})
+}
+
+func BenchmarkDedupHandler(b *testing.B) {
+	benchmarkHandler(b, &DedupHandler{
+		Handler: &JsonHandler{Writer: discardWriter{}},
+		Window:  time.Second,
+	})
+}
+
+func BenchmarkRouterHandler(b *testing.B) {
+	benchmarkHandler(b, &RouterHandler{
+		Path: "logs/{user}.log",
+		NewWriter: func(string) (Writer, error) {
+			return discardWriter{}, nil
+		},
+	})
+}
+
+func BenchmarkFingersCrossedHandler(b *testing.B) {
+	benchmarkHandler(b, &FingersCrossedHandler{
+		Handler:      &JsonHandler{Writer: discardWriter{}},
+		TriggerLevel: errorLevel,
+		MaxEvents:    100,
+	})
+}
+
+func BenchmarkRingBufferHandler(b *testing.B) {
+	benchmarkHandler(b, &RingBufferHandler{Size: 1000})
+}
+
+func BenchmarkFailoverHandler(b *testing.B) {
+	benchmarkHandler(b, &FailoverHandler{
+		Handlers: []Handler{&JsonHandler{Writer: discardWriter{}}, discardHandler{}},
+	})
+}
+
+func BenchmarkMetricsHandler(b *testing.B) {
+	benchmarkHandler(b, &MetricsHandler{
+		Rules: []MetricRule{{Name: "benchmark_events_total", Labels: []string{"level", "session.user"}}},
+	})
+}
+
+func BenchmarkJsonHandlerFields(b *testing.B) {
+	previous := SetHandlers(map[string][]Handler{infoLevel: {&JsonHandler{Writer: discardWriter{}}}})
+	defer SetHandlers(previous)
+	session := benchmarkSession()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		session.EventAt(infoLevel).WithFields(Fields{
+			"status":  200,
+			"latency": 1500 * time.Microsecond,
+			"path":    "/users",
+		}).Print("request completed")
+	}
+}
+
+func BenchmarkJsonHandlerTypedFields(b *testing.B) {
+	previous := SetHandlers(map[string][]Handler{infoLevel: {&JsonHandler{Writer: discardWriter{}}}})
+	defer SetHandlers(previous)
+	session := benchmarkSession()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		session.EventAt(infoLevel).With(
+			Int("status", 200),
+			Duration("latency", 1500*time.Microsecond),
+			String("path", "/users"),
+		).Print("request completed")
+	}
+}
@@ -1,24 +1,94 @@
 package slog
 
+import (
+	"fmt"
+	"reflect"
+)
+
 type HandlerConfig struct {
 	Levels  []string
 	Handler Handler
 }
 
+// Config of handlers and redaction, PanicFallbackToError let error handlers
+// handle panic events if no handler is configured for the panic level, and
+// DisablePipelineMetrics stop recording the metrics of PipelineMetrics.
+// Metrics are rules of a MetricsHandler served by ConfigMetrics, which handle
+// events of MetricsLevels, or of the levels of Handlers if it is empty, the
+// series are kept across loads as long as the rules are not changed.
 type Config struct {
-	Handlers []HandlerConfig
+	Handlers               []HandlerConfig
+	Redaction              []RedactionRule
+	RedactionHashKey       string
+	PanicFallbackToError   bool
+	DisablePipelineMetrics bool
+	Metrics                []MetricRule
+	MetricsLevels          []string
 }
 
-func LoadConfig(config Config) {
+// LoadConfig initialize the configured handlers and replace current handlers
+// and redaction rules, current ones are kept if any handler fails to initialize
+// or any redaction or metric rule is invalid. Replaced handlers not in config
+// are closed.
+func LoadConfig(config Config) error {
+	for _, handler := range config.Handlers {
+		if err := initializeHandler(handler.Handler); err != nil {
+			return fmt.Errorf("initialize handler fail: %s", err.Error())
+		}
+	}
+	redactor, err := newRedactor(config.Redaction, config.RedactionHashKey)
+	if err != nil {
+		return fmt.Errorf("compile redaction rules fail: %s", err.Error())
+	}
+	metrics, err := loadConfigMetrics(config.Metrics)
+	if err != nil {
+		return fmt.Errorf("compile metric rules fail: %s", err.Error())
+	}
 	newHandlers := make(map[string][]Handler)
 	for _, handler := range config.Handlers {
 		for _, level := range handler.Levels {
-			if levelHandlers := handlers[level]; levelHandlers != nil {
-				newHandlers[level] = append(levelHandlers, handler.Handler)
-			} else {
-				newHandlers[level] = []Handler{handler.Handler}
+			newHandlers[level] = append(newHandlers[level], handler.Handler)
+		}
+	}
+	if metrics != nil {
+		metricsLevels := config.MetricsLevels
+		if len(metricsLevels) == 0 {
+			for level := range newHandlers {
+				metricsLevels = append(metricsLevels, level)
 			}
 		}
+		for _, level := range metricsLevels {
+			newHandlers[level] = append(newHandlers[level], metrics)
+		}
 	}
+	handlersLock.Lock()
+	oldHandlers := handlers
 	handlers = newHandlers
+	configMetrics = metrics
+	currentRedactor = redactor
+	panicFallback = config.PanicFallbackToError
+	handlersLock.Unlock()
+	DisablePipelineMetrics(config.DisablePipelineMetrics)
+	// 在锁外关闭, 关闭时处理器可能还需要写日志
+	closeReplacedHandlers(oldHandlers, newHandlers)
+	return nil
+}
+
+// loadConfigMetrics return the handler of rules, the current one is reused if
+// the rules are not changed, so that the series are kept
+func loadConfigMetrics(rules []MetricRule) (*MetricsHandler, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	handlersLock.RLock()
+	current := configMetrics
+	handlersLock.RUnlock()
+	if current != nil && reflect.DeepEqual(current.Rules, rules) {
+		return current, nil
+	}
+	handler := &MetricsHandler{Rules: append([]MetricRule{}, rules...)}
+	if err := handler.Initialize(); err != nil {
+		return nil, err
+	}
+	return handler, nil
 }
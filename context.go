@@ -0,0 +1,20 @@
+package slog
+
+import (
+	"context"
+)
+
+type sessionContextKey struct{}
+
+// NewContext return a copy of ctx carrying session
+func NewContext(ctx context.Context, session Session) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+// FromContext return the session carried by ctx, GlobalSession if there is none
+func FromContext(ctx context.Context) Session {
+	if session, ok := ctx.Value(sessionContextKey{}).(Session); ok {
+		return session
+	}
+	return GlobalSession
+}
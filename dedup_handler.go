@@ -0,0 +1,131 @@
+package slog
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type dedupFingerprint struct {
+	level   string
+	message string
+	pc      uintptr
+	fields  string
+}
+
+// dedupWindow count the repeats of a fingerprint since its first occurrence
+type dedupWindow struct {
+	firstSeen time.Time
+	lastEvent *Event
+	repeats   int
+	timer     *time.Timer
+}
+
+// DedupHandler collapse repeated events. Events are fingerprinted by level,
+// message, caller and the values of FingerprintFields, which are looked up in
+// event, session and global fields. Each fingerprint has its own window, only
+// the first occurrence in Window is forwarded, the repeats are forwarded as one
+// follow-up event with `repeat_count`, `first_seen` and `last_seen` fields when
+// the window closes, so interleaved repeated events are collapsed as well.
+type DedupHandler struct {
+	sync.Mutex
+	Handler           Handler
+	Window            time.Duration
+	FingerprintFields []string
+	windows           map[dedupFingerprint]*dedupWindow
+}
+
+// Initialize initialize the wrapped handler
+func (handler *DedupHandler) Initialize() error {
+	return initializeHandler(handler.Handler)
+}
+
+// Close flush all windows in the order they are opened and close the wrapped handler
+func (handler *DedupHandler) Close() error {
+	handler.Lock()
+	fingerprints := make([]dedupFingerprint, 0, len(handler.windows))
+	for fingerprint := range handler.windows {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Slice(fingerprints, func(i, j int) bool {
+		return handler.windows[fingerprints[i]].firstSeen.Before(handler.windows[fingerprints[j]].firstSeen)
+	})
+	for _, fingerprint := range fingerprints {
+		handler.flush(fingerprint)
+	}
+	handler.Unlock()
+	return closeHandler(handler.Handler)
+}
+
+func (handler *DedupHandler) Handle(event *Event) {
+	handler.Lock()
+	defer handler.Unlock()
+	fingerprint := handler.fingerprint(event)
+	if window := handler.windows[fingerprint]; window != nil {
+		window.lastEvent = event
+		window.repeats++
+		suppressedEventsTotal.add(1, "DedupHandler")
+		return
+	}
+	handler.Handler.Handle(event)
+	handler.open(fingerprint, event)
+}
+
+func (handler *DedupHandler) fingerprint(event *Event) dedupFingerprint {
+	fingerprint := dedupFingerprint{
+		level:   event.Level,
+		message: event.Message,
+		pc:      event.Caller.PC,
+	}
+	if len(handler.FingerprintFields) > 0 {
+		values := make([]interface{}, len(handler.FingerprintFields))
+		for i, key := range handler.FingerprintFields {
+			values[i], _ = event.Field(key)
+		}
+		fingerprint.fields = fmt.Sprintf("%#v", values)
+	}
+	return fingerprint
+}
+
+// open start a new window of fingerprint with event as the first occurrence
+func (handler *DedupHandler) open(fingerprint dedupFingerprint, event *Event) {
+	if handler.Window <= 0 {
+		handler.Window = time.Minute
+	}
+	if handler.windows == nil {
+		handler.windows = make(map[dedupFingerprint]*dedupWindow)
+	}
+	window := &dedupWindow{firstSeen: event.Timestamp}
+	handler.windows[fingerprint] = window
+	window.timer = time.AfterFunc(handler.Window, func() {
+		handler.Lock()
+		defer handler.Unlock()
+		// 窗口已关闭或被新窗口替换时忽略过期的定时器
+		if handler.windows[fingerprint] == window {
+			handler.flush(fingerprint)
+		}
+	})
+}
+
+// flush close the window of fingerprint and forward the follow-up event if any repeat
+func (handler *DedupHandler) flush(fingerprint dedupFingerprint) {
+	window := handler.windows[fingerprint]
+	if window == nil {
+		return
+	}
+	delete(handler.windows, fingerprint)
+	window.timer.Stop()
+	if window.repeats == 0 {
+		return
+	}
+	followUp := *window.lastEvent
+	followUp.Fields = make(Fields, len(window.lastEvent.Fields)+3)
+	for key, value := range window.lastEvent.Fields {
+		followUp.Fields[key] = value
+	}
+	followUp.Fields["repeat_count"] = window.repeats
+	followUp.Fields["first_seen"] = window.firstSeen.Format(time.RFC3339)
+	followUp.Fields["last_seen"] = window.lastEvent.Timestamp.Format(time.RFC3339)
+	handler.Handler.Handle(&followUp)
+}
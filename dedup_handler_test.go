@@ -0,0 +1,105 @@
+package slog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupHandler(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &DedupHandler{
+		Handler: receiver,
+		Window:  time.Minute,
+	}
+	handlers = map[string][]Handler{
+		errorLevel: []Handler{handler},
+	}
+	defer func() { handlers = nil }()
+	for i := 0; i < 5; i++ {
+		newEvent(1, nil).Error("dependency down")
+	}
+	newEvent(1, nil).Error("dependency up")
+	if len(receiver.events) != 2 || receiver.events[1].Message != "dependency up" {
+		t.Error("unexpected events:", receiver.events)
+		return
+	}
+	handler.Close()
+	if len(receiver.events) != 3 {
+		t.Error("unexpected events:", len(receiver.events))
+		return
+	}
+	followUp := receiver.events[2]
+	if followUp.Message != "dependency down" || followUp.Fields["repeat_count"] != 4 ||
+		followUp.Fields["first_seen"] == nil || followUp.Fields["last_seen"] == nil {
+		t.Error("unexpected follow-up event:", followUp)
+	}
+}
+
+func TestDedupHandlerInterleaved(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &DedupHandler{
+		Handler: receiver,
+		Window:  time.Minute,
+	}
+	for i := 0; i < 10; i++ {
+		for _, message := range []string{"a down", "b down"} {
+			event := newEvent(1, nil)
+			event.Level = errorLevel
+			event.Message = message
+			handler.Handle(event)
+		}
+	}
+	if len(receiver.events) != 2 {
+		t.Error("interleaved events not collapsed:", len(receiver.events))
+	}
+	handler.Close()
+	if len(receiver.events) != 4 || receiver.events[2].Message != "a down" || receiver.events[2].Fields["repeat_count"] != 9 ||
+		receiver.events[3].Message != "b down" || receiver.events[3].Fields["repeat_count"] != 9 {
+		t.Error("unexpected follow-up events:", receiver.events)
+	}
+}
+
+func TestDedupHandlerFingerprintFields(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &DedupHandler{
+		Handler:           receiver,
+		Window:            time.Minute,
+		FingerprintFields: []string{"host"},
+	}
+	handlers = map[string][]Handler{
+		errorLevel: []Handler{handler},
+	}
+	defer func() { handlers = nil }()
+	for _, host := range []string{"a", "a", "b", "b"} {
+		newEvent(1, nil).WithField("host", host).Error("dependency down")
+	}
+	if len(receiver.events) != 2 || receiver.events[1].Fields["host"] != "b" {
+		t.Error("unexpected events:", receiver.events)
+	}
+	// 会话中的指纹字段同样区分事件
+	for _, region := range []string{"east", "west", "east"} {
+		NewSession().WithField("host", region).EventSkip(1).Error("dependency down")
+	}
+	if len(receiver.events) != 4 || receiver.events[3].Session["host"] != "west" {
+		t.Error("session fields not fingerprinted:", receiver.events)
+	}
+}
+
+func TestDedupHandlerWindowClose(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &DedupHandler{
+		Handler: receiver,
+		Window:  10 * time.Millisecond,
+	}
+	for i := 0; i < 3; i++ {
+		event := newEvent(1, nil)
+		event.Level = errorLevel
+		handler.Handle(event)
+	}
+	time.Sleep(50 * time.Millisecond)
+	handler.Lock()
+	defer handler.Unlock()
+	if len(receiver.events) != 2 || receiver.events[1].Fields["repeat_count"] != 2 {
+		t.Error("unexpected events:", receiver.events)
+	}
+}
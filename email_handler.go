@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/smtp"
-	"os"
 	"strings"
 	"sync"
 	"time"
@@ -59,14 +58,14 @@ func (handler *PlainTextEmailHandler) aggregateEvents() {
 		buffer.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
 		body, err := handler.ContentFormatter.FormatEvents(events)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "format email body fail:", err.Error())
+			reportError("email", nil, fmt.Errorf("format email body fail: %s", err.Error()))
 			continue
 		}
 		buffer.Write(body)
 		smtpHost := strings.Split(handler.SMTPServer, ":")[0]
 		auth := smtp.PlainAuth("", handler.SMTPUsername, handler.SMTPPassword, smtpHost)
 		if err := smtp.SendMail(handler.SMTPServer, auth, handler.Sender, handler.Receivers, buffer.Bytes()); err != nil {
-			fmt.Fprintln(os.Stderr, "send mail fail:", err.Error())
+			reportError("email", nil, fmt.Errorf("send mail fail: %s", err.Error()))
 		}
 	}
 }
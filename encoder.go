@@ -0,0 +1,394 @@
+package slog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	jsonValueField = iota
+	jsonTypedField
+)
+
+type jsonField struct {
+	key     string
+	kind    int
+	renamed bool
+	value   interface{}
+	index   int
+}
+
+// jsonEncoder encode events as JSON with reusable buffers, the fields are
+// written in the order of Event.RangeFields after timestamp, level, message
+// and caller. It is pooled and must not be used after put back.
+type jsonEncoder struct {
+	content []byte
+	fields  []jsonField
+}
+
+var jsonEncoderPool = sync.Pool{New: func() interface{} {
+	return &jsonEncoder{content: make([]byte, 0, 512), fields: make([]jsonField, 0, 16)}
+}}
+
+func getJSONEncoder() *jsonEncoder {
+	return jsonEncoderPool.Get().(*jsonEncoder)
+}
+
+func putJSONEncoder(encoder *jsonEncoder) {
+	// 过大的缓冲不放回池中, 避免长期占用内存
+	if cap(encoder.content) > 64*1024 {
+		return
+	}
+	for i := range encoder.fields {
+		encoder.fields[i] = jsonField{}
+	}
+	encoder.fields = encoder.fields[:0]
+	jsonEncoderPool.Put(encoder)
+}
+
+func (encoder *jsonEncoder) add(field jsonField, convention map[string]string) {
+	switch field.key {
+	case "level", "message", "timestamp", "caller":
+		return
+	}
+	if name, found := convention[field.key]; found {
+		field.key, field.renamed = name, true
+	}
+	encoder.fields = append(encoder.fields, field)
+}
+
+// dropRenamed remove fields having the names of renamed trace fields, which
+// are preferred as renameTraceFields does
+func (encoder *jsonEncoder) dropRenamed() {
+	fields := encoder.fields[:0]
+	for _, field := range encoder.fields {
+		if !field.renamed && encoder.renamed(field.key) {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	for i := len(fields); i < len(encoder.fields); i++ {
+		encoder.fields[i] = jsonField{}
+	}
+	encoder.fields = fields
+}
+
+func (encoder *jsonEncoder) renamed(key string) bool {
+	for _, field := range encoder.fields {
+		if field.renamed && field.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// encode return the JSON of event, which is valid until the next call
+func (encoder *jsonEncoder) encode(event *Event, timestampFormat, traceConvention string) ([]byte, error) {
+	convention := traceConventions[traceConvention]
+	encoder.fields = encoder.fields[:0]
+	renamed := false
+	event.rangeOrderedFields(func(key string, value interface{}, index int) bool {
+		field := jsonField{key: key, value: value, index: index}
+		if index >= 0 {
+			field.kind = jsonTypedField
+		}
+		encoder.add(field, convention)
+		renamed = renamed || convention[key] != ""
+		return true
+	})
+	if renamed {
+		encoder.dropRenamed()
+	}
+	content := append(encoder.content[:0], `{"timestamp":`...)
+	content = appendJSONTimestamp(content, event.Timestamp, timestampFormat)
+	content = append(content, `,"level":`...)
+	content = appendJSONString(content, event.Level)
+	content = append(content, `,"message":`...)
+	content = appendJSONString(content, event.Message)
+	content = append(content, `,"caller":`...)
+	content = appendJSONCaller(content, &event.Caller)
+	var err error
+	for _, field := range encoder.fields {
+		content = append(content, ',')
+		content = appendJSONString(content, field.key)
+		content = append(content, ':')
+		if field.kind == jsonTypedField {
+			content, err = event.typed[field.index].appendJSON(content)
+		} else {
+			content, err = appendJSONValue(content, resolveValue(field.value))
+		}
+		if err != nil {
+			encoder.content = content
+			return nil, err
+		}
+	}
+	content = append(content, '}')
+	encoder.content = content
+	return content, nil
+}
+
+func appendJSONTimestamp(content []byte, timestamp time.Time, format string) []byte {
+	if format == "" {
+		format = time.RFC3339
+	}
+	start := len(content)
+	content = append(content, '"')
+	content = timestamp.AppendFormat(content, format)
+	for _, b := range content[start+1:] {
+		if b < 0x20 || b == '"' || b == '\\' || b == '<' || b == '>' || b == '&' || b >= utf8.RuneSelf {
+			return appendJSONString(content[:start], string(content[start+1:]))
+		}
+	}
+	return append(content, '"')
+}
+
+func appendJSONCaller(content []byte, caller *Caller) []byte {
+	content = append(content, `{"package":`...)
+	content = appendJSONString(content, caller.Package)
+	content = append(content, `,"file":`...)
+	content = appendJSONString(content, caller.File)
+	content = append(content, `,"func":`...)
+	content = appendJSONString(content, caller.Func)
+	content = append(content, `,"line":`...)
+	content = strconv.AppendInt(content, int64(caller.Line), 10)
+	return append(content, '}')
+}
+
+func appendJSONValue(content []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(content, "null"...), nil
+	case string:
+		return appendJSONString(content, v), nil
+	case bool:
+		return strconv.AppendBool(content, v), nil
+	case int:
+		return strconv.AppendInt(content, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(content, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(content, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(content, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(content, v, 10), nil
+	case uint:
+		return strconv.AppendUint(content, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(content, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(content, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(content, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(content, v, 10), nil
+	case float32:
+		return appendJSONFloat(content, float64(v), 32)
+	case float64:
+		return appendJSONFloat(content, v, 64)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return content, err
+	}
+	return append(content, encoded...), nil
+}
+
+// appendJSONFloat format float as encoding/json does
+func appendJSONFloat(content []byte, value float64, bits int) ([]byte, error) {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return content, fmt.Errorf("json: unsupported value: %s", strconv.FormatFloat(value, 'g', -1, bits))
+	}
+	format := byte('f')
+	if abs := math.Abs(value); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	content = strconv.AppendFloat(content, value, format, -1, bits)
+	if format == 'e' {
+		// 与encoding/json一致, 将e-09改为e-9
+		if n := len(content); n >= 4 && content[n-4] == 'e' && content[n-3] == '-' && content[n-2] == '0' {
+			content[n-2] = content[n-1]
+			content = content[:n-1]
+		}
+	}
+	return content, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString quote text as encoding/json does with HTML escaping
+func appendJSONString(content []byte, text string) []byte {
+	content = append(content, '"')
+	start := 0
+	for i := 0; i < len(text); {
+		if b := text[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			content = append(content, text[start:i]...)
+			switch b {
+			case '\\', '"':
+				content = append(content, '\\', b)
+			case '\b':
+				content = append(content, '\\', 'b')
+			case '\f':
+				content = append(content, '\\', 'f')
+			case '\n':
+				content = append(content, '\\', 'n')
+			case '\r':
+				content = append(content, '\\', 'r')
+			case '\t':
+				content = append(content, '\\', 't')
+			default:
+				content = append(content, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(text[i:])
+		if c == utf8.RuneError && size == 1 {
+			content = append(content, text[start:i]...)
+			content = append(content, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			content = append(content, text[start:i]...)
+			content = append(content, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	content = append(content, text[start:]...)
+	return append(content, '"')
+}
+
+// fieldsJoiner format fields in reusable buffers, entries are sorted as strings
+type fieldsJoiner struct {
+	names   map[string]string
+	entries []byte
+	spans   [][2]int
+	content []byte
+	text    bytes.Buffer
+}
+
+var joinerPool = sync.Pool{New: func() interface{} { return new(fieldsJoiner) }}
+
+func (joiner *fieldsJoiner) Len() int {
+	return len(joiner.spans)
+}
+
+func (joiner *fieldsJoiner) Less(i, j int) bool {
+	a, b := joiner.spans[i], joiner.spans[j]
+	return bytes.Compare(joiner.entries[a[0]:a[1]], joiner.entries[b[0]:b[1]]) < 0
+}
+
+func (joiner *fieldsJoiner) Swap(i, j int) {
+	joiner.spans[i], joiner.spans[j] = joiner.spans[j], joiner.spans[i]
+}
+
+func (joiner *fieldsJoiner) join(fields map[string]interface{}, equal, seperator string, order bool) []byte {
+	joiner.add(fields, equal)
+	return joiner.finish(seperator, order)
+}
+
+// joinOrdered join fields with the keys of order first as rangeOrdered does,
+// keys in names are renamed
+func (joiner *fieldsJoiner) joinOrdered(fields map[string]interface{}, order []string, names map[string]string, equal, seperator string, sorted bool) []byte {
+	joiner.reset()
+	joiner.names = names
+	rangeOrdered(fields, order, func(key string, value interface{}) bool {
+		joiner.addEntry(key, equal, value, nil)
+		return true
+	})
+	return joiner.finish(seperator, sorted)
+}
+
+// joinEvent join event.Fields and typed fields of event in the order they are
+// added, keys in names are renamed
+func (joiner *fieldsJoiner) joinEvent(event *Event, names map[string]string, equal, seperator string, sorted bool) []byte {
+	joiner.reset()
+	joiner.names = names
+	event.rangeOwnFields(func(key string, value interface{}, index int) bool {
+		if index >= 0 {
+			joiner.addEntry(key, equal, nil, &event.typed[index])
+		} else {
+			joiner.addEntry(key, equal, value, nil)
+		}
+		return true
+	})
+	return joiner.finish(seperator, sorted)
+}
+
+func (joiner *fieldsJoiner) reset() {
+	joiner.names = nil
+	joiner.entries = joiner.entries[:0]
+	joiner.spans = joiner.spans[:0]
+}
+
+// addEntry add key and value, or the value of typed field if it is not nil
+func (joiner *fieldsJoiner) addEntry(key, equal string, value interface{}, field *Field) {
+	if name, found := joiner.names[key]; found {
+		key = name
+	}
+	start := len(joiner.entries)
+	joiner.entries = append(append(joiner.entries, key...), equal...)
+	if field != nil {
+		joiner.entries = field.appendText(joiner.entries, joiner)
+	} else {
+		joiner.entries = joiner.appendValue(joiner.entries, value)
+	}
+	joiner.spans = append(joiner.spans, [2]int{start, len(joiner.entries)})
+}
+
+func (joiner *fieldsJoiner) add(fields map[string]interface{}, equal string) {
+	joiner.reset()
+	for key, value := range fields {
+		joiner.addEntry(key, equal, value, nil)
+	}
+}
+
+func (joiner *fieldsJoiner) appendValue(content []byte, value interface{}) []byte {
+	switch v := resolveValue(value).(type) {
+	case string:
+		return strconv.AppendQuote(content, v)
+	case int:
+		return strconv.AppendInt(content, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(content, v, 10)
+	case bool:
+		return strconv.AppendBool(content, v)
+	default:
+		joiner.text.Reset()
+		fmt.Fprintf(&joiner.text, "%v", v)
+		return append(content, joiner.text.Bytes()...)
+	}
+}
+
+func (joiner *fieldsJoiner) finish(seperator string, order bool) []byte {
+	if order {
+		sort.Sort(joiner)
+	}
+	joiner.content = joiner.content[:0]
+	for i, span := range joiner.spans {
+		if i > 0 {
+			joiner.content = append(joiner.content, seperator...)
+		}
+		joiner.content = append(joiner.content, joiner.entries[span[0]:span[1]]...)
+	}
+	return joiner.content
+}
@@ -0,0 +1,127 @@
+package slog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// equalJSON report whether a and b are the same JSON values regardless of key order
+func equalJSON(a, b []byte) bool {
+	var values [2]interface{}
+	for i, content := range [][]byte{a, b} {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.UseNumber()
+		if err := decoder.Decode(&values[i]); err != nil {
+			return false
+		}
+	}
+	return reflect.DeepEqual(values[0], values[1])
+}
+
+func TestJSONEncoder(t *testing.T) {
+	defer func(backup map[string]interface{}) { globalFields = backup }(globalFields)
+	globalFields = map[string]interface{}{"app": "demo", "user": "global", "level": "shadowed"}
+	session := Session{"user": "session", TraceIDKey: "0af7651916cd43dd8448eb211c80319c", "trace.id": "collision"}
+	values := []interface{}{
+		nil, true, 42, int8(-8), int64(math.MinInt64), uint64(math.MaxUint64), uint8(8),
+		0.0, 1.5, -2.25e-7, 1e21, 123456789.0, float32(0.1), float32(1e-7),
+		"plain", "quote\" backslash\\ <html> & \n\r\t\b\f\x01", "unicode 日志 \u2028\u2029", "invalid \xff utf8",
+		time.Second, map[string]interface{}{"b": 1, "a": []int{1, 2}}, errors.New("error"),
+		Lazy(func() interface{} { return "lazy" }), struct{ Name string }{"struct"},
+	}
+	encoder := getJSONEncoder()
+	defer putJSONEncoder(encoder)
+	for _, convention := range []string{"", ElasticTraceConvention, OpenTelemetryTraceConvention} {
+		for _, format := range []string{"", time.RFC3339Nano, "2006-01-02 \"15:04\""} {
+			for _, value := range values {
+				event := newEvent(1, session)
+				event.Level = infoLevel
+				event.Message = "message <b>"
+				event.Fields["value"] = value
+				event.Fields["user"] = "event"
+				event.Fields["message"] = "shadowed"
+				fields := event.Fieldify(format)
+				renameTraceFields(fields, convention)
+				expected, err := json.Marshal(fields)
+				if err != nil {
+					t.Fatal(err)
+				}
+				content, err := encoder.encode(event, format, convention)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !equalJSON(content, expected) {
+					t.Errorf("unexpected json of %#v with %q and %q:\n%s\n%s", value, convention, format, content, expected)
+				}
+			}
+		}
+	}
+	event := newEvent(1, nil)
+	event.Fields["nan"] = math.NaN()
+	if _, err := encoder.encode(event, "", ""); err == nil {
+		t.Error("NaN encoded")
+	}
+}
+
+func TestJoinFieldsBuffered(t *testing.T) {
+	fields := map[string]interface{}{
+		"a": 1, "a-b": "x", "a_b": true, "b": "quote\" 日志", "c": 1.5, "d": int64(-2), "e": nil,
+		"f": []int{1}, "g": Lazy(func() interface{} { return "lazy" }), "h": time.Second,
+	}
+	for _, order := range []bool{true, false} {
+		text := JoinFields(fields, "=", ",", order)
+		parts := strings.Split(text, ",")
+		if len(parts) != len(fields) {
+			t.Fatalf("unexpected joined fields: %s", text)
+		}
+		expected := make([]string, 0, len(fields))
+		for key, value := range fields {
+			value = resolveValue(value)
+			if s, ok := value.(string); ok {
+				expected = append(expected, fmt.Sprintf("%s=%q", key, s))
+			} else {
+				expected = append(expected, fmt.Sprintf("%s=%v", key, value))
+			}
+		}
+		sort.Strings(expected)
+		if !order {
+			sort.Strings(parts)
+		}
+		if strings.Join(parts, ",") != strings.Join(expected, ",") {
+			t.Errorf("unexpected joined fields:\n%s\n%s", strings.Join(parts, ","), strings.Join(expected, ","))
+		}
+	}
+}
+
+func TestEventRangeFields(t *testing.T) {
+	defer func(backup map[string]interface{}) { globalFields = backup }(globalFields)
+	globalFields = map[string]interface{}{"app": "demo", "user": "global"}
+	event := newEvent(1, Session{"user": "session", "request_id": "1"})
+	event.Fields["user"] = "event"
+	event.Fields["lazy"] = Lazy(func() interface{} { return "value" })
+	merged := make(map[string]interface{})
+	event.RangeFields(func(key string, value interface{}) bool {
+		if _, found := merged[key]; found {
+			t.Errorf("duplicated key %q", key)
+		}
+		merged[key] = value
+		return true
+	})
+	if len(merged) != 4 || merged["user"] != "event" || merged["app"] != "demo" || merged["lazy"] != "value" {
+		t.Errorf("unexpected merged fields: %v", merged)
+	}
+	if value, found := event.Field("request_id"); !found || value != "1" {
+		t.Errorf("unexpected field: %v", value)
+	}
+	if _, found := event.Field("missing"); found {
+		t.Error("missing field found")
+	}
+}
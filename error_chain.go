@@ -0,0 +1,175 @@
+package slog
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// errorChainSuffix is appended to the error key for the ErrorChain of errors
+const errorChainSuffix = ".chain"
+
+// limits of ErrorChain, so that cyclic or huge error trees are bounded
+const (
+	maxErrorCauses = 16
+	maxStackFrames = 32
+)
+
+// ErrorCause is an error in ErrorChain with its Go type and stack trace
+type ErrorCause struct {
+	Message string   `json:"message"`
+	Type    string   `json:"type"`
+	Stack   []Caller `json:"stack,omitempty"`
+}
+
+// ErrorChain is an error and its causes in depth first order of errors.Unwrap,
+// errors joined by errors.Join follow the joining error
+type ErrorChain []ErrorCause
+
+// String render chain as "message (type); caused by: message (type) at
+// package.func(file:line)" with the top frame of stack traces
+func (chain ErrorChain) String() string {
+	var builder strings.Builder
+	for i, cause := range chain {
+		if i > 0 {
+			builder.WriteString("; caused by: ")
+		}
+		builder.WriteString(cause.Message)
+		builder.WriteString(" (")
+		builder.WriteString(cause.Type)
+		builder.WriteString(")")
+		if len(cause.Stack) > 0 {
+			frame := cause.Stack[0]
+			builder.WriteString(" at ")
+			builder.WriteString(frame.Package)
+			builder.WriteString(".")
+			builder.WriteString(frame.Func)
+			builder.WriteString("(")
+			builder.WriteString(frame.File)
+			builder.WriteString(":")
+			builder.WriteString(strconv.Itoa(frame.Line))
+			builder.WriteString(")")
+		}
+	}
+	return builder.String()
+}
+
+// rewriteMessages return the copy of chain with messages rewritten by
+// function, or chain itself if no message is changed
+func (chain ErrorChain) rewriteMessages(function func(string) string) (ErrorChain, bool) {
+	var rewritten ErrorChain
+	for i, cause := range chain {
+		message := function(cause.Message)
+		if message == cause.Message {
+			continue
+		}
+		if rewritten == nil {
+			rewritten = append(ErrorChain(nil), chain...)
+		}
+		rewritten[i].Message = message
+	}
+	if rewritten == nil {
+		return chain, false
+	}
+	return rewritten, true
+}
+
+// NewErrorChain walk err and the errors it wraps, the chain of an error
+// neither wrapping others nor carrying a stack trace has only itself
+func NewErrorChain(err error) ErrorChain {
+	var chain ErrorChain
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil || len(chain) >= maxErrorCauses {
+			return
+		}
+		chain = append(chain, ErrorCause{
+			Message: err.Error(),
+			Type:    reflect.TypeOf(err).String(),
+			Stack:   errorStack(err),
+		})
+		switch wrapper := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, cause := range wrapper.Unwrap() {
+				walk(cause)
+			}
+		case interface{ Unwrap() error }:
+			walk(wrapper.Unwrap())
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// errorStack return the stack trace carried by err. Errors of github.com/pkg/errors
+// and compatible packages have StackTrace returning a slice of program
+// counters, and errors of github.com/go-errors/errors have Callers.
+func errorStack(err error) []Caller {
+	var pcs []uintptr
+	if callers, ok := err.(interface{ Callers() []uintptr }); ok {
+		pcs = callers.Callers()
+	} else {
+		value := reflect.ValueOf(err)
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			return nil
+		}
+		// StackTrace的返回类型由各错误库定义, 只能通过反射识别
+		method := value.MethodByName("StackTrace")
+		if !method.IsValid() {
+			return nil
+		}
+		methodType := method.Type()
+		if methodType.NumIn() != 0 || methodType.NumOut() != 1 ||
+			methodType.Out(0).Kind() != reflect.Slice || methodType.Out(0).Elem().Kind() != reflect.Uintptr {
+			return nil
+		}
+		frames := method.Call(nil)[0]
+		pcs = make([]uintptr, frames.Len())
+		for i := range pcs {
+			pcs[i] = uintptr(frames.Index(i).Uint())
+		}
+	}
+	if len(pcs) == 0 {
+		return nil
+	}
+	if len(pcs) > maxStackFrames {
+		pcs = pcs[:maxStackFrames]
+	}
+	stack := make([]Caller, 0, len(pcs))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, frameCaller(frame))
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// clearError forget the error and its ErrorChain when the error key is
+// replaced by a field which is not an error
+func (event *Event) clearError() {
+	event.err = nil
+	chainKey := errorKey + errorChainSuffix
+	delete(event.Fields, chainKey)
+	if len(event.typed) > 0 {
+		event.dropTyped(chainKey)
+	}
+}
+
+// withErrorChain add the ErrorChain of err with key+".chain" if it has causes
+// or stack traces, or remove the chain of an error previously added with key
+func (event *Event) withErrorChain(key string, err error) {
+	chainKey := key + errorChainSuffix
+	chain := NewErrorChain(err)
+	if len(chain) > 1 || len(chain) == 1 && len(chain[0].Stack) > 0 {
+		event.WithField(chainKey, chain)
+	} else {
+		delete(event.Fields, chainKey)
+		if len(event.typed) > 0 {
+			event.dropTyped(chainKey)
+		}
+	}
+}
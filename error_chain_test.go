@@ -0,0 +1,166 @@
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type testFrame uintptr
+
+type testStackTrace []testFrame
+
+// stackError carries a stack trace as errors of github.com/pkg/errors do
+type stackError struct {
+	message string
+	stack   []uintptr
+}
+
+func newStackError(message string) error {
+	pcs := make([]uintptr, 8)
+	return &stackError{message, pcs[:runtime.Callers(2, pcs)]}
+}
+
+func (err *stackError) Error() string {
+	return err.message
+}
+
+func (err *stackError) StackTrace() testStackTrace {
+	frames := make(testStackTrace, len(err.stack))
+	for i, pc := range err.stack {
+		frames[i] = testFrame(pc)
+	}
+	return frames
+}
+
+// callersError carries a stack trace as errors of github.com/go-errors/errors do
+type callersError struct {
+	stack []uintptr
+}
+
+func (err *callersError) Error() string {
+	return "callers"
+}
+
+func (err *callersError) Callers() []uintptr {
+	return err.stack
+}
+
+type cyclicError struct{}
+
+func (err *cyclicError) Error() string {
+	return "cyclic"
+}
+
+func (err *cyclicError) Unwrap() error {
+	return err
+}
+
+func TestNewErrorChain(t *testing.T) {
+	err := fmt.Errorf("outer: %w", newStackError("inner"))
+	chain := NewErrorChain(err)
+	if len(chain) != 2 || chain[0].Message != "outer: inner" || chain[0].Type != "*fmt.wrapError" ||
+		chain[1].Type != "*slog.stackError" || len(chain[0].Stack) != 0 {
+		t.Fatalf("unexpected chain: %#v", chain)
+	}
+	if frame := chain[1].Stack[0]; frame.Func != "TestNewErrorChain" || frame.File != "error_chain_test.go" {
+		t.Errorf("unexpected stack: %#v", chain[1].Stack)
+	}
+	if text := chain.String(); !strings.HasPrefix(text, "outer: inner (*fmt.wrapError); caused by: inner (*slog.stackError) at ") ||
+		!strings.Contains(text, ".TestNewErrorChain(error_chain_test.go:") {
+		t.Errorf("unexpected text: %s", text)
+	}
+
+	pcs := make([]uintptr, 4)
+	joined := errors.Join(errors.New("first"), &callersError{pcs[:runtime.Callers(1, pcs)]})
+	chain = NewErrorChain(joined)
+	if len(chain) != 3 || chain[0].Type != "*errors.joinError" || chain[1].Message != "first" ||
+		len(chain[2].Stack) == 0 || chain[2].Stack[0].Func != "TestNewErrorChain" {
+		t.Errorf("unexpected joined chain: %#v", chain)
+	}
+	if chain = NewErrorChain(&cyclicError{}); len(chain) != maxErrorCauses {
+		t.Errorf("unexpected cyclic chain length: %d", len(chain))
+	}
+}
+
+func TestEventWithErrorChain(t *testing.T) {
+	chainKey := errorKey + errorChainSuffix
+	event := newEvent(1, nil).WithError(errors.New("plain"))
+	if _, found := event.Fields[chainKey]; found {
+		t.Error("chain added for plain error")
+	}
+	event.WithError(fmt.Errorf("wrapped: %w", errors.New("plain")))
+	if chain, ok := event.Fields[chainKey].(ErrorChain); !ok || len(chain) != 2 {
+		t.Errorf("unexpected chain: %v", event.Fields[chainKey])
+	}
+	event.WithError(errors.New("plain"))
+	if _, found := event.Fields[chainKey]; found {
+		t.Error("chain of previous error not removed")
+	}
+	event.With(Err(newStackError("typed")))
+	if chain, ok := event.Fields[chainKey].(ErrorChain); !ok || len(chain) != 1 || len(chain[0].Stack) == 0 {
+		t.Errorf("unexpected chain of typed error: %v", event.Fields[chainKey])
+	}
+
+	event = newEvent(1, nil).WithError(fmt.Errorf("wrapped: %w", errors.New("cause")))
+	encoder := getJSONEncoder()
+	defer putJSONEncoder(encoder)
+	content, err := encoder.encode(event, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `"` + chainKey + `":[{"message":"wrapped: cause","type":"*fmt.wrapError"},{"message":"cause","type":"*errors.errorString"}]`
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("unexpected json: %s", content)
+	}
+	text := joinEventFields(event, nil, "=", " ", false)
+	if !strings.Contains(text, chainKey+"=wrapped: cause (*fmt.wrapError); caused by: cause (*errors.errorString)") {
+		t.Errorf("unexpected text: %s", text)
+	}
+}
+
+func TestEventErrorReplaced(t *testing.T) {
+	chainKey := errorKey + errorChainSuffix
+	wrapped := fmt.Errorf("wrapped: %w", errors.New("cause"))
+	replacements := map[string]func(event *Event){
+		"WithField":  func(event *Event) { event.WithField(errorKey, "text") },
+		"WithFields": func(event *Event) { event.WithFields(Fields{errorKey: "text", "other": 1}) },
+		"String":     func(event *Event) { event.With(String(errorKey, "text")) },
+		"Err(nil)":   func(event *Event) { event.With(Err(nil)) },
+	}
+	for name, replace := range replacements {
+		event := newEvent(1, nil).WithError(wrapped)
+		replace(event)
+		if _, found := event.Field(chainKey); found || event.err != nil {
+			t.Errorf("error kept after %s: %v %v", name, event.err, event.Fields)
+		}
+		event = newEvent(1, nil).With(Err(wrapped))
+		replace(event)
+		if _, found := event.Field(chainKey); found || event.err != nil {
+			t.Errorf("typed error kept after %s: %v %v", name, event.err, event.typed)
+		}
+	}
+}
+
+func TestErrorChainRedactedAndLimited(t *testing.T) {
+	chain := NewErrorChain(fmt.Errorf("user alice@example.com: %w", newStackError("no password for alice@example.com")))
+	redactor, _ := newRedactor([]RedactionRule{{Pattern: EmailPattern}}, "")
+	redacted, changed := redactor.redactValue(chain, 0)
+	if text := fmt.Sprint(redacted); !changed || strings.Contains(text, "alice") || strings.Contains(chain.String(), fullMaskText) {
+		t.Errorf("unexpected redacted chain: %s", text)
+	}
+
+	limits := &Limits{MaxValueBytes: 4, MaxDepth: 2}
+	limited, changed := limits.limitValue(chain, 1)
+	if limitedChain := limited.(ErrorChain); !changed || limitedChain[0].Message != "user" || len(limitedChain[1].Stack) != 0 {
+		t.Errorf("unexpected limited chain: %#v", limited)
+	}
+	if len(chain[1].Stack) == 0 || chain[0].Message != "user alice@example.com: no password for alice@example.com" {
+		t.Error("original chain changed")
+	}
+	if limited, _ = (&Limits{MaxDepth: 3}).limitValue(chain, 1); len(limited.(ErrorChain)[1].Stack) == 0 {
+		t.Error("stack dropped within MaxDepth")
+	}
+}
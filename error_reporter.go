@@ -0,0 +1,70 @@
+package slog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrorHandler receive internal errors of handlers and writers. source is the
+// type name of the failing handler or writer, such as "json" or
+// "time_rotated_file", and event is nil if the error is not about an event.
+// Errors are printed to stderr at most StderrErrorLimit if it is nil.
+var ErrorHandler func(source string, event *Event, err error)
+
+// StderrErrorLimit limit the rate of errors printed to stderr
+var StderrErrorLimit = RateLimit{Rate: 1, Burst: 10}
+
+var (
+	stderrLock       sync.Mutex
+	stderrBucket     tokenBucket
+	stderrSuppressed int
+)
+
+// ErrorCounts return numbers of internal errors by source
+func ErrorCounts() map[string]uint64 {
+	errorsTotal.Lock()
+	defer errorsTotal.Unlock()
+	counts := make(map[string]uint64, len(errorsTotal.series))
+	for _, series := range errorsTotal.series {
+		counts[series.labelValues[0]] = uint64(loadFloat(&series.value))
+	}
+	return counts
+}
+
+// reportError count err of source and pass it to ErrorHandler or stderr
+func reportError(source string, event *Event, err error) {
+	errorsTotal.add(1, source)
+	stderrLock.Lock()
+	handler := ErrorHandler
+	allowed, suppressed := true, 0
+	if handler == nil {
+		stderrBucket.refill(StderrErrorLimit, time.Now())
+		if allowed = stderrBucket.tokens >= 1; allowed {
+			stderrBucket.tokens--
+			suppressed, stderrSuppressed = stderrSuppressed, 0
+		} else {
+			stderrSuppressed++
+		}
+	}
+	stderrLock.Unlock()
+	if handler != nil {
+		handler(source, event, err)
+		return
+	}
+	if !allowed {
+		return
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(os.Stderr, "suppressed %d internal errors\n", suppressed)
+	}
+	// 保持原有的错误输出格式
+	if herr, ok := err.(*handleError); ok && event != nil {
+		fmt.Fprintf(os.Stderr, "%s: event=%v, error=%q\n", herr.stage, event, herr.cause.Error())
+	} else if event != nil {
+		fmt.Fprintf(os.Stderr, "%s: event=%v, error=%q\n", source, event, err.Error())
+	} else {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
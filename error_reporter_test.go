@@ -0,0 +1,70 @@
+package slog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	var sources []string
+	var events []*Event
+	ErrorHandler = func(source string, event *Event, err error) {
+		sources = append(sources, source)
+		events = append(events, event)
+	}
+	defer func() { ErrorHandler = nil }()
+	before := ErrorCounts()["json"]
+	event := newEvent(1, nil)
+	handler := &JsonHandler{
+		Writer: &errorWriter{err: errors.New("_error_")},
+	}
+	handler.Handle(event)
+	if len(sources) != 1 || sources[0] != "json" || events[0] != event {
+		t.Error("unexpected errors:", sources, events)
+	}
+	if count := ErrorCounts()["json"]; count != before+1 {
+		t.Error("unexpected error count:", count)
+	}
+}
+
+func TestErrorReporterStderrLimit(t *testing.T) {
+	os.Remove("temp.txt")
+	tempfile, err := os.OpenFile("temp.txt", os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		t.Error("open file fail:", err.Error())
+		return
+	}
+	defer func() {
+		tempfile.Close()
+		os.Remove("temp.txt")
+	}()
+	stderr := os.Stderr
+	os.Stderr = tempfile
+	defer func() {
+		os.Stderr = stderr
+	}()
+	limit := StderrErrorLimit
+	StderrErrorLimit = RateLimit{Rate: 0.001, Burst: 2}
+	stderrBucket = tokenBucket{}
+	defer func() {
+		StderrErrorLimit = limit
+		stderrBucket = tokenBucket{}
+	}()
+	for i := 0; i < 5; i++ {
+		reportError("test", nil, errors.New("_error_"))
+	}
+	tempfile.Close()
+	content, err := ioutil.ReadFile("temp.txt")
+	if err != nil {
+		t.Error("read temp.txt fail:", err.Error())
+	} else if strings.Count(string(content), "_error_") != 2 {
+		t.Error("unexpected error messages:", string(content))
+	}
+	if stderrSuppressed != 3 {
+		t.Error("unexpected suppressed count:", stderrSuppressed)
+	}
+	stderrSuppressed = 0
+}
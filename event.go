@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"runtime"
 	"runtime/debug"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,14 +18,16 @@ var (
 	warnLevel  = "warn"
 	errorLevel = "error"
 	fatalLevel = "fatal"
+	panicLevel = "panic"
 )
 
 // Caller provide caller information of log event
 type Caller struct {
-	Package string `json:"package"`
-	File    string `json:"file"`
-	Func    string `json:"func"`
-	Line    int    `json:"line"`
+	Package string  `json:"package"`
+	File    string  `json:"file"`
+	Func    string  `json:"func"`
+	Line    int     `json:"line"`
+	PC      uintptr `json:"-"`
 }
 
 // Event represents the log event
@@ -34,12 +38,32 @@ type Event struct {
 	Session   Session
 	Fields    Fields
 	Caller    Caller
+
+	err           error
+	globals       map[string]interface{}
+	typed         []Field
+	typedBuffer   [4]Field
+	order         []string
+	orderBuffer   [8]string
+	originSession Session
+	disabled      bool
+	pooled        bool
 }
 
 // Fields type, used by `WithFields`
 type Fields map[string]interface{}
 
+// maxPooledFields limit the size of fields maps kept in the pool
+const maxPooledFields = 64
+
 var (
+	// disabledEvent is returned for levels without handlers of GlobalSession, it
+	// must not be changed
+	disabledEvent = &Event{disabled: true}
+	eventPool     = sync.Pool{New: func() interface{} {
+		return &Event{Fields: make(Fields)}
+	}}
+	callerCacheLock    sync.RWMutex
 	callerCache        = make(map[uintptr]Caller)
 	errorKey           = "error"
 	stackKey           = "stack"
@@ -63,46 +87,137 @@ func SetStackKey(key string) {
 }
 
 func newEvent(skip int, session Session) *Event {
-	event := &Event{
-		Timestamp: time.Now(),
-		Fields:    make(map[string]interface{}),
-	}
-	// 获取Caller信息
-	pc, file, line, ok := runtime.Caller(skip)
-	if ok {
-		caller, found := callerCache[pc]
+	event := &Event{Fields: make(map[string]interface{})}
+	event.init(skip+1, session)
+	return event
+}
+
+// newPooledEvent get an event from the pool, it is put back after it is written
+// if all handlers are transient, so it must not be exposed to callers
+func newPooledEvent(skip int, session Session) *Event {
+	event := eventPool.Get().(*Event)
+	event.pooled = true
+	event.init(skip+1, session)
+	return event
+}
+
+// release clear event and put it back to the pool
+func (event *Event) release() {
+	fields := event.Fields
+	if len(fields) > maxPooledFields {
+		fields = make(Fields)
+	} else {
+		for key := range fields {
+			delete(fields, key)
+		}
+	}
+	typed := event.typed
+	if len(typed) > maxPooledFields {
+		typed = nil
+	} else {
+		for i := range typed {
+			typed[i] = Field{}
+		}
+		typed = typed[:0]
+	}
+	order := event.order
+	if len(order) > maxPooledFields {
+		order = nil
+	} else {
+		order = order[:0]
+	}
+	*event = Event{Fields: fields, typed: typed, order: order}
+	eventPool.Put(event)
+}
+
+func (event *Event) init(skip int, session Session) {
+	event.Timestamp = time.Now()
+	event.Caller = Caller{}
+	// 获取Caller信息, 仅在缓存未命中时解析栈帧
+	var pcs [1]uintptr
+	if runtime.Callers(skip+1, pcs[:]) > 0 {
+		callerCacheLock.RLock()
+		caller, found := callerCache[pcs[0]]
+		callerCacheLock.RUnlock()
 		if !found {
-			if ffp := runtime.FuncForPC(pc); ffp != nil {
-				fullfunc := ffp.Name()
-				caller.Func = funcnamePattern.FindString(fullfunc)[1:]
-				caller.Package = fullfunc[:len(fullfunc)-len(caller.Func)-1]
-			}
-			caller.File = filepath.Base(file)
-			caller.Line = line
-			callerCache[pc] = caller
+			frame, _ := runtime.CallersFrames([]uintptr{pcs[0]}).Next()
+			caller = frameCaller(frame)
+			callerCacheLock.Lock()
+			callerCache[pcs[0]] = caller
+			callerCacheLock.Unlock()
 		}
 		event.Caller = caller
 	}
 	event.Session = session
-	return event
 }
 
-// WithError add err.Error() to event.Fields with errorKey
+// frameCaller convert frame to Caller
+func frameCaller(frame runtime.Frame) Caller {
+	var caller Caller
+	if frame.Function != "" {
+		caller.Func = funcnamePattern.FindString(frame.Function)[1:]
+		caller.Package = frame.Function[:len(frame.Function)-len(caller.Func)-1]
+	}
+	caller.File = filepath.Base(frame.File)
+	caller.Line = frame.Line
+	caller.PC = frame.PC
+	return caller
+}
+
+// WithError add err.Error() to event.Fields with errorKey, if err wraps other
+// errors or carries a stack trace, its ErrorChain is added with errorKey+".chain"
 func (event *Event) WithError(err error) *Event {
+	if event.disabled {
+		return event
+	}
+	event.recordKey(errorKey)
 	event.Fields[errorKey] = err.Error()
+	event.err = err
+	if len(event.typed) > 0 {
+		event.dropTyped(errorKey)
+	}
+	event.withErrorChain(errorKey, err)
 	return event
 }
 
 // WithField add value to event.Fields with key
 func (event *Event) WithField(key string, value interface{}) *Event {
+	if event.disabled {
+		return event
+	}
+	event.recordKey(key)
 	event.Fields[key] = value
+	if len(event.typed) > 0 {
+		event.dropTyped(key)
+	}
+	if key == errorKey {
+		event.clearError()
+	}
 	return event
 }
 
 // WithFields add multiple key value pairs to event.Fields
 func (event *Event) WithFields(fields Fields) *Event {
+	if event.disabled {
+		return event
+	}
+	if len(fields) == 1 {
+		for key := range fields {
+			event.recordKey(key)
+		}
+	} else {
+		for _, key := range sortedKeys(fields) {
+			event.recordKey(key)
+		}
+	}
 	for key, value := range fields {
 		event.Fields[key] = value
+		if len(event.typed) > 0 {
+			event.dropTyped(key)
+		}
+		if key == errorKey {
+			event.clearError()
+		}
 	}
 	return event
 }
@@ -185,30 +300,84 @@ func (event *Event) Fatalln(args ...interface{}) {
 
 // Panic throw `panic` event with fmt.Sprint
 func (event *Event) Panic(args ...interface{}) {
-	event.Level = "panic"
-	event.Message = fmt.Sprint(args...)
-	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	event.enable().throw(fmt.Sprint(args...))
 }
 
 // Panicf throw `panic` event with fmt.Sprintf
 func (event *Event) Panicf(format string, args ...interface{}) {
-	event.Level = "panic"
-	event.Message = fmt.Sprintf(format, args...)
-	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	event.enable().throw(fmt.Sprintf(format, args...))
 }
 
 // Panicln throw `panic` event with fmt.Sprintln
 func (event *Event) Panicln(args ...interface{}) {
-	event.Level = "panic"
-	event.Message = fmt.Sprintln(args...)
+	event.enable().throw(fmt.Sprintln(args...))
+}
+
+// throw write event at panic level, then panic with its PanicError
+func (event *Event) throw(message string) {
+	event.Level = panicLevel
+	event.Message = message
 	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	// 事件随panic传出, 不能放回池中
+	event.pooled = false
+	event.materialize()
+	event.write()
+	panic(&PanicError{Event: event})
+}
+
+// PanicError is the value Panic, Panicf and Panicln panic with after the
+// panic event is handled, Unwrap return the error added by WithError or Err
+type PanicError struct {
+	Event *Event
+}
+
+func (err *PanicError) Error() string {
+	message := strings.TrimSuffix(err.Event.Message, "\n")
+	if err.Event.err == nil {
+		return message
+	} else if message == "" {
+		return err.Event.err.Error()
+	}
+	return message + ": " + err.Event.err.Error()
+}
+
+func (err *PanicError) Unwrap() error {
+	return err.Event.err
+}
+
+// enable return event itself, or a new event of the disabled event's session
+// in place of it, the caller is the one calling Panic, Panicf or Panicln
+func (event *Event) enable() *Event {
+	if !event.disabled {
+		return event
+	}
+	session := event.Session
+	if session == nil {
+		session = GlobalSession
+	}
+	return newEvent(3, session)
+}
+
+// Print write event created by EventAt at its level with fmt.Sprint
+func (event *Event) Print(args ...interface{}) {
+	event.Log(event.Level, args...)
+}
+
+// Printf write event created by EventAt at its level with fmt.Sprintf
+func (event *Event) Printf(format string, args ...interface{}) {
+	event.Logf(event.Level, format, args...)
+}
+
+// Println write event created by EventAt at its level with fmt.Sprintln
+func (event *Event) Println(args ...interface{}) {
+	event.Logln(event.Level, args...)
 }
 
 // Log write event with customized level and fmt.Sprint
 func (event *Event) Log(level string, args ...interface{}) {
+	if event.disabled || !Enabled(level) {
+		return
+	}
 	event.Message = fmt.Sprint(args...)
 	event.Level = level
 	event.write()
@@ -216,6 +385,9 @@ func (event *Event) Log(level string, args ...interface{}) {
 
 // Logf write event with customized level and fmt.Sprintf
 func (event *Event) Logf(level string, format string, args ...interface{}) {
+	if event.disabled || !Enabled(level) {
+		return
+	}
 	event.Message = fmt.Sprintf(format, args...)
 	event.Level = level
 	event.write()
@@ -223,6 +395,9 @@ func (event *Event) Logf(level string, format string, args ...interface{}) {
 
 // Logln write event with customized level and fmt.Sprintln
 func (event *Event) Logln(level string, args ...interface{}) {
+	if event.disabled || !Enabled(level) {
+		return
+	}
 	event.Message = fmt.Sprintln(args...)
 	event.Level = level
 	event.write()
@@ -231,14 +406,22 @@ func (event *Event) Logln(level string, args ...interface{}) {
 // Fieldify convert event to map[string]interface{}
 func (event *Event) Fieldify(timestampFormat string) map[string]interface{} {
 	fields := make(map[string]interface{})
-	for key, value := range globalFields {
-		fields[key] = value
+	event.fieldifyInto(fields, timestampFormat)
+	return fields
+}
+
+func (event *Event) fieldifyInto(fields map[string]interface{}, timestampFormat string) {
+	for key, value := range event.globalView() {
+		fields[key] = resolveValue(value)
 	}
 	for key, value := range event.Session {
-		fields[key] = value
+		fields[key] = resolveValue(value)
 	}
 	for key, value := range event.Fields {
-		fields[key] = value
+		fields[key] = resolveValue(value)
+	}
+	for i := range event.typed {
+		fields[event.typed[i].Key] = resolveValue(event.typed[i].Value())
 	}
 	fields["level"] = event.Level
 	fields["message"] = event.Message
@@ -248,17 +431,114 @@ func (event *Event) Fieldify(timestampFormat string) map[string]interface{} {
 		fields["timestamp"] = event.Timestamp.Format(time.RFC3339)
 	}
 	fields["caller"] = event.Caller
-	return fields
+}
+
+// Field return the value of key in event fields, session fields or global
+// fields in this order, without building the merged map of Fieldify
+func (event *Event) Field(key string) (interface{}, bool) {
+	if value, found := event.Fields[key]; found {
+		return resolveValue(value), true
+	}
+	if i := event.typedIndex(key); i >= 0 {
+		return resolveValue(event.typed[i].Value()), true
+	}
+	if value, found := event.Session[key]; found {
+		return resolveValue(value), true
+	}
+	if value, found := event.globalView()[key]; found {
+		return resolveValue(value), true
+	}
+	return nil, false
+}
+
+// RangeFields call function with each key and value of the merged global
+// fields, session fields and event fields as in Fieldify without copying them,
+// in the order they are written, it stops if function returns false
+func (event *Event) RangeFields(function func(key string, value interface{}) bool) {
+	event.rangeOrderedFields(func(key string, value interface{}, index int) bool {
+		if index >= 0 {
+			value = event.typed[index].Value()
+		}
+		return function(key, resolveValue(value))
+	})
+}
+
+// Clone copy event with its fields and session, handlers must keep clones
+// instead of events which are reused after handled by transient handlers
+func (event *Event) Clone() *Event {
+	return event.snapshot()
+}
+
+// snapshot copy event with its fields and session, so that later changes of them are not seen
+func (event *Event) snapshot() *Event {
+	snapshot := *event
+	snapshot.pooled = false
+	snapshot.typed = nil
+	snapshot.typedBuffer = [4]Field{}
+	snapshot.order = nil
+	snapshot.orderBuffer = [8]string{}
+	if len(event.order) > 0 {
+		snapshot.order = append(snapshot.orderBuffer[:0], event.order...)
+	}
+	snapshot.Fields = make(Fields, len(event.Fields))
+	for key, value := range event.Fields {
+		snapshot.Fields[key] = value
+	}
+	if len(event.typed) > 0 {
+		snapshot.typed = append(snapshot.typedBuffer[:0], event.typed...)
+	}
+	if event.Session != nil {
+		snapshot.Session = make(Session, len(event.Session))
+		for key, value := range event.Session {
+			snapshot.Session[key] = value
+		}
+	}
+	return &snapshot
+}
+
+// InSession report whether event is created by session, the copies of session
+// made for redaction and limits are not distinguished from session
+func (event *Event) InSession(session Session) bool {
+	return session != nil && event.sessionID() == sessionID(session)
+}
+
+// origin return the session creating event, which is kept when the session is
+// copied for redaction and limits
+func (event *Event) origin() Session {
+	if event.originSession != nil {
+		return event.originSession
+	}
+	return event.Session
+}
+
+// sessionID return the identity of the session creating event
+func (event *Event) sessionID() uintptr {
+	return sessionID(event.origin())
 }
 
 func (event *Event) write() {
 	var levelHandlers []Handler
-	if handlers == nil {
-		levelHandlers = defaultHandlers[event.Level]
-	} else {
-		levelHandlers = handlers[event.Level]
+	handlersLock.RLock()
+	levelHandlers = handlersOf(event.Level)
+	redactor := currentRedactor
+	handlersLock.RUnlock()
+	if len(event.typed) > 0 && (redactor != nil || !writesTypedFields(levelHandlers)) {
+		event.materialize()
 	}
+	if len(levelHandlers) > 0 {
+		event.resolveValuers()
+	}
+	handled := event
+	if redactor != nil {
+		handled = redactor.redact(event)
+	}
+	countEvent(event.Level)
+	transient := true
 	for _, handler := range levelHandlers {
-		handler.Handle(event)
+		handleEvent(handler, handled)
+		transient = transient && isTransient(handler)
+	}
+	if event.pooled && transient {
+		event.release()
 	}
 }
@@ -431,9 +431,9 @@ func TestEventPanic(t *testing.T) {
 	defer func() {
 		if event := recover(); event == nil {
 			t.Error("no panic")
-		} else if event, ok := event.(*Event); !ok {
+		} else if err, ok := event.(*PanicError); !ok {
 			t.Error("invalid panic type:", event)
-		} else if event.Level != "panic" || event.Message != fmt.Sprint("test", "event") {
+		} else if event := err.Event; event.Level != "panic" || event.Message != fmt.Sprint("test", "event") {
 			t.Error("unexpected event:", event)
 		} else if _, found := event.Fields[stackKey]; !found {
 			t.Error("miss stack")
@@ -447,9 +447,9 @@ func TestEventPanicf(t *testing.T) {
 	defer func() {
 		if event := recover(); event == nil {
 			t.Error("no panic")
-		} else if event, ok := event.(*Event); !ok {
+		} else if err, ok := event.(*PanicError); !ok {
 			t.Error("invalid panic type:", event)
-		} else if event.Level != "panic" || event.Message != fmt.Sprintf("test %s", "event") {
+		} else if event := err.Event; event.Level != "panic" || event.Message != fmt.Sprintf("test %s", "event") {
 			t.Error("unexpected event:", event)
 		} else if _, found := event.Fields[stackKey]; !found {
 			t.Error("miss stack")
@@ -463,9 +463,9 @@ func TestEventPanicln(t *testing.T) {
 	defer func() {
 		if event := recover(); event == nil {
 			t.Error("no panic")
-		} else if event, ok := event.(*Event); !ok {
+		} else if err, ok := event.(*PanicError); !ok {
 			t.Error("invalid panic type:", event)
-		} else if event.Level != "panic" || event.Message != fmt.Sprintln("test", "event") {
+		} else if event := err.Event; event.Level != "panic" || event.Message != fmt.Sprintln("test", "event") {
 			t.Error("unexpected event:", event)
 		} else if _, found := event.Fields[stackKey]; !found {
 			t.Error("miss stack")
@@ -497,3 +497,150 @@ func TestEventDefaultHandler(t *testing.T) {
 		return
 	}
 }
+
+type countingStringer struct {
+	count *int
+}
+
+func (stringer countingStringer) String() string {
+	*stringer.count++
+	return "counted"
+}
+
+func TestEnabled(t *testing.T) {
+	handler := new(receiveHandler)
+	handlers = map[string][]Handler{
+		infoLevel: []Handler{handler},
+	}
+	if !Enabled(infoLevel) || Enabled(debugLevel) {
+		t.Error("unexpected enabled levels")
+	}
+	count := 0
+	Debug(countingStringer{&count})
+	NewSession().Debugf("%s", countingStringer{&count})
+	newEvent(1, nil).Logln(debugLevel, countingStringer{&count})
+	if count != 0 || len(handler.events) != 0 {
+		t.Errorf("disabled level formatted %d times, handled %d events", count, len(handler.events))
+	}
+	Info(countingStringer{&count})
+	if count != 1 || len(handler.events) != 1 {
+		t.Errorf("enabled level formatted %d times, handled %d events", count, len(handler.events))
+	}
+}
+
+func TestEventAt(t *testing.T) {
+	handler := new(receiveHandler)
+	handlers = map[string][]Handler{
+		infoLevel: []Handler{handler},
+	}
+	if event := EventAt(debugLevel); event != disabledEvent {
+		t.Error("unexpected event of disabled level:", event)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		NewSession().EventAt(debugLevel).WithField("key", 1).WithError(errors.New("error")).Print("message")
+	})
+	if allocs > 3 {
+		t.Errorf("disabled event allocated %v times", allocs)
+	}
+	if len(disabledEvent.Fields) != 0 || disabledEvent.Message != "" {
+		t.Error("disabled event changed:", disabledEvent)
+	}
+	EventAt(infoLevel).WithField("key", 1).Printf("%s %d", "message", 1)
+	if len(handler.events) != 1 {
+		t.Fatal("unexpected events:", handler.events)
+	}
+	event := handler.events[0]
+	if event.Level != infoLevel || event.Message != "message 1" || event.Fields["key"] != 1 ||
+		event.Caller.File != "event_test.go" || event.Caller.Func != "TestEventAt" {
+		t.Error("unexpected event:", event)
+	}
+	session := NewSession().WithField("tenant", "acme")
+	func() {
+		defer func() {
+			if err, ok := recover().(*PanicError); !ok {
+				t.Error("disabled event did not panic")
+			} else if !err.Event.InSession(session) || err.Event.Session["tenant"] != "acme" ||
+				err.Event.Caller.File != "event_test.go" || err.Event.Message != "panic" {
+				t.Error("unexpected panic event:", err.Event)
+			}
+		}()
+		session.EventAt(debugLevel).Panic("panic")
+	}()
+	defer func() {
+		if err, ok := recover().(*PanicError); !ok {
+			t.Error("disabled event did not panic")
+		} else if !err.Event.InSession(GlobalSession) || err.Event.Caller.Func != "TestEventAt" {
+			t.Error("unexpected panic event:", err.Event)
+		}
+	}()
+	EventAt(debugLevel).Panic("panic")
+}
+
+func TestPooledEvent(t *testing.T) {
+	transient := &JsonHandler{Writer: discardWriter{}}
+	keeping := new(receiveHandler)
+	handlers = map[string][]Handler{
+		infoLevel: []Handler{transient},
+		warnLevel: []Handler{transient, keeping},
+	}
+	session := NewSession()
+	session.Warn("kept")
+	session.Info("released")
+	session.Warn("kept again")
+	if len(keeping.events) != 2 || keeping.events[0].Message != "kept" || keeping.events[1].Message != "kept again" {
+		t.Errorf("kept events reused: %v", keeping.events)
+	}
+	if !isTransient(&FilterHandler{Handler: transient}) || isTransient(&FilterHandler{Handler: transient, Else: keeping}) {
+		t.Error("unexpected transient of wrapping handler")
+	}
+	if raceEnabled {
+		t.Skip("allocations are not counted under the race detector")
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		session.Info("message")
+	})
+	if allocs > 1 {
+		t.Errorf("pooled event allocated %v times", allocs)
+	}
+}
+
+func TestPanicHandled(t *testing.T) {
+	panicHandler, errorHandler := new(receiveHandler), new(receiveHandler)
+	defer SetHandlers(SetHandlers(map[string][]Handler{
+		panicLevel: {panicHandler},
+		errorLevel: {errorHandler},
+	}))
+	cause := errors.New("cause")
+	func() {
+		defer func() {
+			err, ok := recover().(*PanicError)
+			if !ok {
+				t.Fatal("unexpected panic value")
+			}
+			if err.Error() != "test: cause" || !errors.Is(err, cause) {
+				t.Errorf("unexpected panic error: %v", err)
+			}
+		}()
+		NewSession().Event().WithError(cause).Panicln("test")
+	}()
+	if len(panicHandler.events) != 1 || panicHandler.events[0].Level != panicLevel || len(errorHandler.events) != 0 {
+		t.Errorf("unexpected handled events: %v %v", panicHandler.events, errorHandler.events)
+	}
+
+	SetHandlers(map[string][]Handler{errorLevel: {errorHandler}})
+	defer func(fallback bool) { panicFallback = fallback }(panicFallback)
+	for _, fallback := range []bool{false, true} {
+		panicFallback = fallback
+		func() {
+			defer func() {
+				if err, ok := recover().(error); !ok || err.Error() != "fallback" || errors.Unwrap(err) != nil {
+					t.Errorf("unexpected panic error: %v", err)
+				}
+			}()
+			Panic("fallback")
+		}()
+	}
+	if len(errorHandler.events) != 1 || errorHandler.events[0].Message != "fallback" {
+		t.Errorf("unexpected fallback events: %v", errorHandler.events)
+	}
+}
@@ -14,6 +14,15 @@ var (
 func init() {
 	HandlerFactory.RegisterType("json", reflect.TypeOf((*JsonHandler)(nil)).Elem())
 	HandlerFactory.RegisterType("plaintext", reflect.TypeOf((*PlainTextHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("sampling", reflect.TypeOf((*SamplingHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("rate_limit", reflect.TypeOf((*RateLimitHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("dedup", reflect.TypeOf((*DedupHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("filter", reflect.TypeOf((*FilterHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("router", reflect.TypeOf((*RouterHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("fingers_crossed", reflect.TypeOf((*FingersCrossedHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("ring_buffer", reflect.TypeOf((*RingBufferHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("failover", reflect.TypeOf((*FailoverHandler)(nil)).Elem())
+	HandlerFactory.RegisterType("metrics", reflect.TypeOf((*MetricsHandler)(nil)).Elem())
 
 	WriterFactory.RegisterInstance("stdout", StdoutWriter)
 	WriterFactory.RegisterInstance("stderr", StderrWriter)
@@ -0,0 +1,194 @@
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Prober is implemented by handlers and writers which can check their health
+// without handling an event. JsonHandler and PlainTextHandler probe their
+// writers, and return errProbeUnsupported if the writers are not Probers.
+type Prober interface {
+	Probe() error
+}
+
+var errProbeUnsupported = errors.New("probe unsupported")
+
+// tryProbe probe writer if it is a Prober
+func tryProbe(writer Writer) error {
+	if prober, ok := writer.(Prober); ok {
+		return prober.Probe()
+	}
+	return errProbeUnsupported
+}
+
+// FailoverHandler try Handlers in order and stay on the first one succeeding.
+// Only handlers implementing CheckedHandler can fail. While a secondary handler
+// is active, the preferred handlers are probed every ProbeInterval: those
+// implementing Prober are probed in the background, the others, including the
+// JsonHandler and PlainTextHandler whose writers are not Probers, are retried
+// with the next event. Failover and recovery are reported as ReportLevel events to
+// the newly active handler.
+type FailoverHandler struct {
+	sync.Mutex
+	Handlers      []Handler
+	ProbeInterval time.Duration
+	ReportLevel   string
+	current       int
+	retry         bool
+	probing       bool
+	closed        bool
+}
+
+// Initialize initialize the wrapped handlers
+func (handler *FailoverHandler) Initialize() error {
+	if len(handler.Handlers) == 0 {
+		return fmt.Errorf("no failover handlers")
+	}
+	for _, target := range handler.Handlers {
+		if err := initializeHandler(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stop probing and close the wrapped handlers
+func (handler *FailoverHandler) Close() error {
+	handler.Lock()
+	handler.closed = true
+	handler.Unlock()
+	var err error
+	for _, target := range handler.Handlers {
+		if closeErr := closeHandler(target); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (handler *FailoverHandler) Handle(event *Event) {
+	if err := handler.TryHandle(event); err != nil {
+		reportError("failover", event, err)
+	}
+}
+
+// Transient return true if all wrapped handlers are transient
+func (handler *FailoverHandler) Transient() bool {
+	for _, wrapped := range handler.Handlers {
+		if !isTransient(wrapped) {
+			return false
+		}
+	}
+	return true
+}
+
+func (handler *FailoverHandler) TryHandle(event *Event) error {
+	handler.Lock()
+	start := handler.current
+	if handler.retry {
+		start = 0
+		handler.retry = false
+	}
+	handler.Unlock()
+	var lastErr error
+	for i := start; i < len(handler.Handlers); i++ {
+		err := tryHandle(handler.Handlers[i], event)
+		if err == nil {
+			handler.switchTo(i, lastErr)
+			return nil
+		}
+		lastErr = err
+	}
+	return &handleError{"all failover handlers fail", lastErr}
+}
+
+func tryHandle(handler Handler, event *Event) error {
+	if checked, ok := handler.(CheckedHandler); ok {
+		return checked.TryHandle(event)
+	}
+	handler.Handle(event)
+	return nil
+}
+
+// switchTo make handler i active and report the change, err is the error of the previous handler
+func (handler *FailoverHandler) switchTo(i int, err error) {
+	handler.Lock()
+	previous := handler.current
+	if previous == i {
+		handler.Unlock()
+		return
+	}
+	handler.current = i
+	if i > 0 && !handler.probing {
+		handler.probing = true
+		go handler.probe()
+	}
+	handler.Unlock()
+	level := handler.ReportLevel
+	if level == "" {
+		level = warnLevel
+	}
+	report := &Event{
+		Timestamp: time.Now(),
+		Level:     level,
+		Fields: Fields{
+			"from": previous,
+			"to":   i,
+		},
+	}
+	if i > previous {
+		report.Message = "log handler failover"
+		if err != nil {
+			report.Fields[errorKey] = err.Error()
+		}
+	} else {
+		report.Message = "log handler recovered"
+	}
+	tryHandle(handler.Handlers[i], report)
+}
+
+func (handler *FailoverHandler) probe() {
+	if handler.ProbeInterval <= 0 {
+		handler.ProbeInterval = 10 * time.Second
+	}
+	ticker := time.NewTicker(handler.ProbeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if handler.probeOnce() {
+			return
+		}
+	}
+}
+
+// probeOnce probe the handlers preferred to the active one, true if the primary
+// one is active or the handler is closed
+func (handler *FailoverHandler) probeOnce() bool {
+	handler.Lock()
+	current := handler.current
+	if current == 0 || handler.closed {
+		handler.probing = false
+		handler.Unlock()
+		return true
+	}
+	handler.Unlock()
+	for i := 0; i < current; i++ {
+		err := errProbeUnsupported
+		if prober, ok := handler.Handlers[i].(Prober); ok {
+			err = prober.Probe()
+		}
+		if err == errProbeUnsupported {
+			handler.Lock()
+			handler.retry = true
+			handler.Unlock()
+			return false
+		}
+		if err == nil {
+			handler.switchTo(i, nil)
+			return false
+		}
+	}
+	return false
+}
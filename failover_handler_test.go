@@ -0,0 +1,128 @@
+package slog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type probeWriter struct {
+	bufferWriter
+	err error
+}
+
+func (writer *probeWriter) Write(b []byte) error {
+	if writer.err != nil {
+		return writer.err
+	}
+	return writer.bufferWriter.Write(b)
+}
+
+type probeHandler struct {
+	JsonHandler
+}
+
+func (handler *probeHandler) Probe() error {
+	return handler.Writer.(*probeWriter).err
+}
+
+func TestFailoverHandler(t *testing.T) {
+	primary := &probeWriter{err: errors.New("connection refused")}
+	secondary := new(receiveHandler)
+	handler := &FailoverHandler{
+		Handlers: []Handler{
+			&probeHandler{JsonHandler{Writer: primary}},
+			secondary,
+		},
+		ProbeInterval: time.Hour,
+	}
+	if err := handler.TryHandle(newEvent(1, nil)); err != nil {
+		t.Error("handle fail:", err.Error())
+	}
+	if len(secondary.events) != 2 || secondary.events[1].Message != "log handler failover" ||
+		secondary.events[1].Fields[errorKey] == nil {
+		t.Error("unexpected secondary events:", secondary.events)
+		return
+	}
+	// 主handler恢复前不再重试
+	handler.TryHandle(newEvent(1, nil))
+	if len(secondary.events) != 3 {
+		t.Error("unexpected secondary events:", secondary.events)
+	}
+	if handler.probeOnce() || handler.current != 1 {
+		t.Error("unexpected recovery")
+	}
+	primary.err = nil
+	handler.probeOnce()
+	if handler.current != 0 || primary.Len() == 0 {
+		t.Error("unexpected current handler:", handler.current)
+	}
+	if !handler.probeOnce() {
+		t.Error("unexpected probing")
+	}
+}
+
+func TestFailoverHandlerRetry(t *testing.T) {
+	primary := &errorWriter{err: errors.New("connection refused")}
+	secondary := &errorWriter{err: errors.New("disk full")}
+	handler := &FailoverHandler{
+		Handlers: []Handler{
+			&JsonHandler{Writer: primary},
+			&JsonHandler{Writer: secondary},
+		},
+	}
+	if err := handler.TryHandle(newEvent(1, nil)); err == nil {
+		t.Error("unexpected success")
+	}
+	receiver := new(receiveHandler)
+	handler.Handlers[1] = receiver
+	handler.TryHandle(newEvent(1, nil))
+	if handler.current != 1 {
+		t.Error("unexpected current handler:", handler.current)
+	}
+	primary.err = nil
+	handler.probeOnce()
+	if !handler.retry {
+		t.Error("miss retry")
+	}
+	handler.TryHandle(newEvent(1, nil))
+	if handler.current != 0 || len(receiver.events) != 2 {
+		t.Error("unexpected recovery:", handler.current, receiver.events)
+	}
+}
+
+func TestFailoverHandlerProbeWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slog")
+	if err != nil {
+		t.Fatal("create temp dir fail:", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	primary := &TimeRotatedFileWriter{
+		Path:            filepath.Join(dir, "logs", "app.log"),
+		TimestampFormat: "20060102",
+		Interval:        time.Hour,
+	}
+	defer primary.Close()
+	secondary := new(receiveHandler)
+	handler := &FailoverHandler{
+		Handlers:      []Handler{&JsonHandler{Writer: primary}, secondary},
+		ProbeInterval: time.Hour,
+	}
+	if err := (&JsonHandler{Writer: new(bufferWriter)}).Probe(); err != errProbeUnsupported {
+		t.Error("unexpected probe result:", err)
+	}
+	handler.TryHandle(newEvent(1, nil))
+	if handler.probeOnce() || handler.current != 1 || handler.retry {
+		t.Error("unexpected recovery:", handler.current, handler.retry)
+	}
+	if err := os.MkdirAll(filepath.Dir(primary.Path), 0755); err != nil {
+		t.Fatal("create log dir fail:", err.Error())
+	}
+	handler.probeOnce()
+	if handler.current != 0 || len(secondary.events) != 2 {
+		t.Error("unexpected recovery:", handler.current, secondary.events)
+	}
+}
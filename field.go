@@ -0,0 +1,268 @@
+package slog
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type fieldKind uint8
+
+const (
+	anyField fieldKind = iota
+	stringField
+	int64Field
+	float64Field
+	boolField
+	durationField
+	timeField
+	errorField
+	stringerField
+)
+
+// Field is a key value pair added by With. Typed fields keep their values
+// unboxed in the event, so that JsonHandler and PlainTextHandler write them
+// without reflection or fmt, other handlers see them in event.Fields.
+type Field struct {
+	Key     string
+	kind    fieldKind
+	integer int64
+	text    string
+	value   interface{}
+}
+
+// String create a string field
+func String(key, value string) Field {
+	return Field{Key: key, kind: stringField, text: value}
+}
+
+// Int create an int field, it is written as int64
+func Int(key string, value int) Field {
+	return Field{Key: key, kind: int64Field, integer: int64(value)}
+}
+
+// Int64 create an int64 field
+func Int64(key string, value int64) Field {
+	return Field{Key: key, kind: int64Field, integer: value}
+}
+
+// Float64 create a float64 field
+func Float64(key string, value float64) Field {
+	return Field{Key: key, kind: float64Field, integer: int64(math.Float64bits(value))}
+}
+
+// Bool create a bool field
+func Bool(key string, value bool) Field {
+	field := Field{Key: key, kind: boolField}
+	if value {
+		field.integer = 1
+	}
+	return field
+}
+
+// Duration create a time.Duration field
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, kind: durationField, integer: int64(value)}
+}
+
+// Time create a time.Time field, times out of the range of UnixNano are boxed
+func Time(key string, value time.Time) Field {
+	nanos := value.UnixNano()
+	if !time.Unix(0, nanos).Equal(value) {
+		return Any(key, value)
+	}
+	return Field{Key: key, kind: timeField, integer: nanos, value: value.Location()}
+}
+
+// Err create a field of err.Error() with the error key, its ErrorChain is
+// added by Event.With as WithError does
+func Err(err error) Field {
+	return Field{Key: errorKey, kind: errorField, value: err}
+}
+
+// Stringer create a field of value.String(), which is called when the field is written
+func Stringer(key string, value fmt.Stringer) Field {
+	return Field{Key: key, kind: stringerField, value: value}
+}
+
+// Any create a field of any value, it is written as values of WithField
+func Any(key string, value interface{}) Field {
+	return Field{Key: key, kind: anyField, value: value}
+}
+
+// Value return the value of field as it is stored in event.Fields
+func (field Field) Value() interface{} {
+	switch field.kind {
+	case stringField:
+		return field.text
+	case int64Field:
+		return field.integer
+	case float64Field:
+		return math.Float64frombits(uint64(field.integer))
+	case boolField:
+		return field.integer == 1
+	case durationField:
+		return time.Duration(field.integer)
+	case timeField:
+		return field.time()
+	case errorField:
+		if field.value == nil {
+			return nil
+		}
+		return field.value.(error).Error()
+	case stringerField:
+		if field.value == nil {
+			return nil
+		}
+		return field.value.(fmt.Stringer).String()
+	}
+	return field.value
+}
+
+func (field *Field) time() time.Time {
+	return time.Unix(0, field.integer).In(field.value.(*time.Location))
+}
+
+// appendJSON append the JSON of field value as json.Marshal(field.Value()) does
+func (field *Field) appendJSON(content []byte) ([]byte, error) {
+	switch field.kind {
+	case stringField:
+		return appendJSONString(content, field.text), nil
+	case int64Field, durationField:
+		return strconv.AppendInt(content, field.integer, 10), nil
+	case float64Field:
+		return appendJSONFloat(content, math.Float64frombits(uint64(field.integer)), 64)
+	case boolField:
+		return strconv.AppendBool(content, field.integer == 1), nil
+	case timeField:
+		content = append(content, '"')
+		content = field.time().AppendFormat(content, time.RFC3339Nano)
+		return append(content, '"'), nil
+	case errorField:
+		if field.value == nil {
+			return append(content, "null"...), nil
+		}
+		return appendJSONString(content, field.value.(error).Error()), nil
+	case stringerField:
+		if field.value == nil {
+			return append(content, "null"...), nil
+		}
+		return appendJSONString(content, field.value.(fmt.Stringer).String()), nil
+	}
+	return appendJSONValue(content, resolveValue(field.value))
+}
+
+// appendText append the text of field value as JoinFields does, only the
+// values of any fields are formatted with fmt
+func (field *Field) appendText(content []byte, buffer *fieldsJoiner) []byte {
+	switch field.kind {
+	case stringField:
+		return strconv.AppendQuote(content, field.text)
+	case int64Field:
+		return strconv.AppendInt(content, field.integer, 10)
+	case float64Field:
+		return strconv.AppendFloat(content, math.Float64frombits(uint64(field.integer)), 'g', -1, 64)
+	case boolField:
+		return strconv.AppendBool(content, field.integer == 1)
+	case durationField:
+		return append(content, time.Duration(field.integer).String()...)
+	case timeField:
+		return field.time().AppendFormat(content, "2006-01-02 15:04:05.999999999 -0700 MST")
+	case errorField, stringerField:
+		if field.value == nil {
+			return append(content, "<nil>"...)
+		}
+		return strconv.AppendQuote(content, field.Value().(string))
+	}
+	return buffer.appendValue(content, field.value)
+}
+
+// With add typed fields to event, which replace fields of the same keys
+func (event *Event) With(fields ...Field) *Event {
+	if event.disabled {
+		return event
+	}
+	if event.typed == nil {
+		// 少量字段使用事件内的缓冲, 避免额外分配
+		event.typed = event.typedBuffer[:0]
+	}
+	for _, field := range fields {
+		event.recordKey(field.Key)
+		if _, found := event.Fields[field.Key]; found {
+			delete(event.Fields, field.Key)
+		}
+		if i := event.typedIndex(field.Key); i >= 0 {
+			event.typed[i] = field
+		} else {
+			event.typed = append(event.typed, field)
+		}
+		if field.kind == errorField && field.value != nil {
+			event.err = field.value.(error)
+			event.withErrorChain(field.Key, event.err)
+		} else if field.Key == errorKey {
+			event.clearError()
+		}
+	}
+	return event
+}
+
+// With add fields to session, their values are stored as in event.Fields
+func (session Session) With(fields ...Field) Session {
+	for _, field := range fields {
+		recordSessionKeys(session, field.Key)
+		session[field.Key] = field.Value()
+	}
+	return session
+}
+
+func (event *Event) typedIndex(key string) int {
+	for i := range event.typed {
+		if event.typed[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// dropTyped remove the typed field of key, which is replaced by a field of event.Fields
+func (event *Event) dropTyped(key string) {
+	if i := event.typedIndex(key); i >= 0 {
+		last := len(event.typed) - 1
+		copy(event.typed[i:], event.typed[i+1:])
+		event.typed[last] = Field{}
+		event.typed = event.typed[:last]
+	}
+}
+
+// hasField report whether key is in event.Fields or typed fields
+func (event *Event) hasField(key string) bool {
+	if _, found := event.Fields[key]; found {
+		return true
+	}
+	return event.typedIndex(key) >= 0
+}
+
+// materialize move typed fields into event.Fields for handlers reading it
+func (event *Event) materialize() {
+	for i := range event.typed {
+		event.Fields[event.typed[i].Key] = event.typed[i].Value()
+		event.typed[i] = Field{}
+	}
+	event.typed = event.typed[:0]
+}
+
+// typedFieldsHandler is implemented by handlers writing typed fields directly,
+// events are given to other handlers with typed fields in event.Fields
+type typedFieldsHandler interface {
+	writesTypedFields() bool
+}
+
+func writesTypedFields(handlers []Handler) bool {
+	for _, handler := range handlers {
+		if typed, ok := handler.(typedFieldsHandler); !ok || !typed.writesTypedFields() {
+			return false
+		}
+	}
+	return true
+}
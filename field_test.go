@@ -0,0 +1,132 @@
+package slog
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func typedTestFields() []Field {
+	return []Field{
+		String("string", "quote\" <html> 日志"), Int("int", -1), Int64("int64", math.MaxInt64),
+		Float64("float64", 1.5), Float64("small", -2.25e-7), Bool("true", true), Bool("false", false),
+		Duration("duration", 1500*time.Millisecond),
+		Time("time", time.Date(2020, 1, 2, 3, 4, 5, 6, time.FixedZone("CST", 8*3600))),
+		Time("ancient", time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC)),
+		Err(errors.New("failed")), Stringer("ip", net.IPv4(127, 0, 0, 1)), Stringer("nil", nil),
+		Any("any", map[string]int{"a": 1}), Any("lazy", Lazy(func() interface{} { return "lazy" })),
+		String(TraceIDKey, "0af7651916cd43dd8448eb211c80319c"),
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	now := time.Now()
+	cases := map[interface{}]interface{}{
+		String("k", "v").Value():                    "v",
+		Int("k", 1).Value():                         int64(1),
+		Float64("k", 0.5).Value():                   0.5,
+		Bool("k", true).Value():                     true,
+		Duration("k", time.Second).Value():          time.Second,
+		Err(errors.New("failed")).Value():           "failed",
+		Stringer("k", net.IPv4(1, 2, 3, 4)).Value(): "1.2.3.4",
+		Any("k", uint8(1)).Value():                  uint8(1),
+	}
+	for value, expected := range cases {
+		if value != expected {
+			t.Errorf("unexpected value %#v, expected %#v", value, expected)
+		}
+	}
+	if value := Time("k", now).Value().(time.Time); !value.Equal(now) || value.Location() != now.Location() {
+		t.Errorf("unexpected time: %v", value)
+	}
+	if field := Err(nil); field.Key != errorKey || field.Value() != nil {
+		t.Errorf("unexpected nil error field: %#v", field)
+	}
+}
+
+func TestEventWith(t *testing.T) {
+	event := newEvent(1, nil)
+	event.WithField("a", 1).With(String("a", "typed"), Int("b", 2))
+	if _, found := event.Fields["a"]; found {
+		t.Error("field not replaced by typed field")
+	}
+	if value, _ := event.Field("a"); value != "typed" {
+		t.Errorf("unexpected field: %v", value)
+	}
+	event.WithFields(Fields{"b": "plain"}).With(Int("c", 3), Int("c", 4))
+	if len(event.typed) != 2 || event.typed[0].Key != "a" || event.typed[1].Value() != int64(4) {
+		t.Errorf("unexpected typed fields: %v", event.typed)
+	}
+	if fields := event.Fieldify(""); fields["a"] != "typed" || fields["b"] != "plain" || fields["c"] != int64(4) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if disabledEvent.With(String("a", "b")); len(disabledEvent.typed) != 0 {
+		t.Error("disabled event changed")
+	}
+	session := NewSession().With(Duration("elapsed", time.Second))
+	if session["elapsed"] != time.Second {
+		t.Errorf("unexpected session: %v", session)
+	}
+}
+
+func TestTypedFieldsJSON(t *testing.T) {
+	encoder := getJSONEncoder()
+	defer putJSONEncoder(encoder)
+	session := Session{"string": "shadowed", TraceIDKey: "shadowed", "user": "session"}
+	for _, convention := range []string{"", ElasticTraceConvention} {
+		event := newEvent(1, session).With(typedTestFields()...)
+		event.Level = infoLevel
+		event.Message = "message"
+		content, err := encoder.encode(event, "", convention)
+		if err != nil {
+			t.Fatal(err)
+		}
+		typed := string(content)
+		event.materialize()
+		fields := event.Fieldify("")
+		renameTraceFields(fields, convention)
+		expected, err := json.Marshal(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalJSON([]byte(typed), expected) {
+			t.Errorf("unexpected json with %q:\n%s\n%s", convention, typed, expected)
+		}
+	}
+	event := newEvent(1, nil).With(Float64("nan", math.NaN()))
+	if _, err := encoder.encode(event, "", ""); err == nil {
+		t.Error("NaN encoded")
+	}
+}
+
+func TestTypedFieldsText(t *testing.T) {
+	event := newEvent(1, nil).With(typedTestFields()...)
+	event.Fields["plain"] = "value"
+	text := joinEventFields(event, nil, "=", ",", true)
+	event.materialize()
+	expected := JoinFields(event.Fields, "=", ",", true)
+	if text != expected {
+		t.Errorf("unexpected text:\n%s\n%s", text, expected)
+	}
+}
+
+func TestTypedFieldsHandlers(t *testing.T) {
+	writer := new(bufferWriter)
+	receiver := new(receiveHandler)
+	defer SetHandlers(SetHandlers(map[string][]Handler{
+		infoLevel: {&JsonHandler{Writer: writer}},
+		warnLevel: {&JsonHandler{Writer: writer}, receiver},
+	}))
+	NewSession().Event().With(Int("status", 200)).Info("typed")
+	if !strings.Contains(writer.String(), `"status":200`) {
+		t.Errorf("unexpected json: %s", writer.String())
+	}
+	NewSession().Event().With(Int("status", 500)).Warn("materialized")
+	if len(receiver.events) != 1 || receiver.events[0].Fields["status"] != int64(500) {
+		t.Errorf("typed fields not in event.Fields: %v", receiver.events)
+	}
+}
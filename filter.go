@@ -0,0 +1,502 @@
+package slog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var levelRanks = map[string]int{
+	debugLevel: 0,
+	infoLevel:  1,
+	warnLevel:  2,
+	errorLevel: 3,
+	fatalLevel: 4,
+	"panic":    5,
+}
+
+// Filter is a compiled filter expression, such as
+//
+//	level >= warn && fields.tenant == "acme" && message =~ "timeout"
+//
+// Supported references are level, message, caller.package, caller.file,
+// caller.func, caller.line, session.<key>, fields.<key> and global.<key>,
+// nested map values can be referenced with more dots. Bare level names
+// (debug, info, warn, error, fatal, panic) are level constants, ordering
+// operators compare levels by severity.
+type Filter struct {
+	expression string
+	root       filterNode
+}
+
+// CompileFilter parse the expression into a Filter
+func CompileFilter(expression string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, fmt.Errorf("compile filter %q fail: %s", expression, err.Error())
+	}
+	parser := &filterParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err == nil && parser.pos < len(parser.tokens) {
+		err = fmt.Errorf("unexpected %q", parser.tokens[parser.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("compile filter %q fail: %s", expression, err.Error())
+	}
+	return &Filter{expression: expression, root: root}, nil
+}
+
+// Match report whether event satisfy the filter
+func (filter *Filter) Match(event *Event) bool {
+	return truthy(filter.root.eval(event))
+}
+
+func (filter *Filter) String() string {
+	return filter.expression
+}
+
+type filterTokenKind int
+
+const (
+	filterIdent filterTokenKind = iota
+	filterString
+	filterNumber
+	filterOperator
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+}
+
+var filterOperators = []string{"&&", "||", "==", "!=", "=~", "!~", ">=", "<=", ">", "<", "!", "(", ")"}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0, 16)
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			// 查找未转义的结束引号
+			j := i + 1
+			for ; j < len(expression) && expression[j] != '"'; j++ {
+				if expression[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(expression) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text, err := strconv.Unquote(expression[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %s", i, err.Error())
+			}
+			tokens = append(tokens, filterToken{filterString, text})
+			i = j + 1
+		case unicode.IsDigit(c) || (c == '-' && i+1 < len(expression) && unicode.IsDigit(rune(expression[i+1]))):
+			j := i + 1
+			for j < len(expression) && (unicode.IsDigit(rune(expression[j])) || expression[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, filterToken{filterNumber, expression[i:j]})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(expression) && isFilterIdentRune(rune(expression[j])) {
+				j++
+			}
+			tokens = append(tokens, filterToken{filterIdent, expression[i:j]})
+			i = j
+		default:
+			matched := false
+			for _, operator := range filterOperators {
+				if strings.HasPrefix(expression[i:], operator) {
+					tokens = append(tokens, filterToken{filterOperator, operator})
+					i += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+func isFilterIdentRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' || c == '-'
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (parser *filterParser) peekOperator(operators ...string) string {
+	if parser.pos >= len(parser.tokens) || parser.tokens[parser.pos].kind != filterOperator {
+		return ""
+	}
+	for _, operator := range operators {
+		if parser.tokens[parser.pos].text == operator {
+			return operator
+		}
+	}
+	return ""
+}
+
+func (parser *filterParser) parseOr() (filterNode, error) {
+	left, err := parser.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peekOperator("||") != "" {
+		parser.pos++
+		right, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseAnd() (filterNode, error) {
+	left, err := parser.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for parser.peekOperator("&&") != "" {
+		parser.pos++
+		right, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (parser *filterParser) parseUnary() (filterNode, error) {
+	if parser.peekOperator("!") != "" {
+		parser.pos++
+		operand, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNot{operand}, nil
+	}
+	return parser.parseComparison()
+}
+
+func (parser *filterParser) parseComparison() (filterNode, error) {
+	left, err := parser.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	operator := parser.peekOperator("==", "!=", ">=", "<=", ">", "<", "=~", "!~")
+	if operator == "" {
+		return left, nil
+	}
+	parser.pos++
+	right, err := parser.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if operator == "=~" || operator == "!~" {
+		literal, ok := right.(filterLiteral)
+		pattern, isString := literal.value.(string)
+		if !ok || !isString {
+			return nil, fmt.Errorf("right operand of %s must be a string", operator)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp %q: %s", pattern, err.Error())
+		}
+		return &filterMatch{left: left, pattern: re, negate: operator == "!~"}, nil
+	}
+	_, leftIsLevel := left.(filterLevel)
+	_, rightIsLevel := right.(filterLevel)
+	return &filterCompare{
+		operator: operator,
+		left:     left,
+		right:    right,
+		byLevel:  leftIsLevel || rightIsLevel,
+	}, nil
+}
+
+func (parser *filterParser) parseOperand() (filterNode, error) {
+	if parser.pos >= len(parser.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	token := parser.tokens[parser.pos]
+	parser.pos++
+	switch token.kind {
+	case filterString:
+		return filterLiteral{token.text}, nil
+	case filterNumber:
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token.text)
+		}
+		return filterLiteral{number}, nil
+	case filterIdent:
+		return parseFilterReference(token.text)
+	}
+	if token.text == "(" {
+		node, err := parser.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if parser.peekOperator(")") == "" {
+			return nil, fmt.Errorf("missing )")
+		}
+		parser.pos++
+		return node, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", token.text)
+}
+
+func parseFilterReference(name string) (filterNode, error) {
+	switch name {
+	case "true":
+		return filterLiteral{true}, nil
+	case "false":
+		return filterLiteral{false}, nil
+	case "level":
+		return filterLevel{}, nil
+	case "message":
+		return filterFunc(func(event *Event) interface{} { return event.Message }), nil
+	case "caller.package":
+		return filterFunc(func(event *Event) interface{} { return event.Caller.Package }), nil
+	case "caller.file":
+		return filterFunc(func(event *Event) interface{} { return event.Caller.File }), nil
+	case "caller.func":
+		return filterFunc(func(event *Event) interface{} { return event.Caller.Func }), nil
+	case "caller.line":
+		return filterFunc(func(event *Event) interface{} { return event.Caller.Line }), nil
+	}
+	if _, found := levelRanks[name]; found {
+		return filterLiteral{name}, nil
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("unknown reference %q", name)
+	}
+	path := parts[1:]
+	switch parts[0] {
+	case "fields":
+		return filterFunc(func(event *Event) interface{} { return lookupField(event.Fields, path) }), nil
+	case "session":
+		return filterFunc(func(event *Event) interface{} { return lookupField(event.Session, path) }), nil
+	case "global":
+		return filterFunc(func(event *Event) interface{} { return lookupField(event.globalView(), path) }), nil
+	}
+	return nil, fmt.Errorf("unknown reference %q", name)
+}
+
+// lookupField find value of dotted path in nested maps, nil if not found
+func lookupField(fields map[string]interface{}, path []string) interface{} {
+	var value interface{} = fields
+	for _, key := range path {
+		switch m := value.(type) {
+		case map[string]interface{}:
+			value = resolveValue(m[key])
+		case Fields:
+			value = resolveValue(m[key])
+		case Session:
+			value = resolveValue(m[key])
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+type filterNode interface {
+	eval(*Event) interface{}
+}
+
+type filterLiteral struct {
+	value interface{}
+}
+
+func (node filterLiteral) eval(*Event) interface{} {
+	return node.value
+}
+
+type filterLevel struct{}
+
+func (filterLevel) eval(event *Event) interface{} {
+	return event.Level
+}
+
+type filterFunc func(*Event) interface{}
+
+func (node filterFunc) eval(event *Event) interface{} {
+	return node(event)
+}
+
+type filterOr struct {
+	left, right filterNode
+}
+
+func (node *filterOr) eval(event *Event) interface{} {
+	return truthy(node.left.eval(event)) || truthy(node.right.eval(event))
+}
+
+type filterAnd struct {
+	left, right filterNode
+}
+
+func (node *filterAnd) eval(event *Event) interface{} {
+	return truthy(node.left.eval(event)) && truthy(node.right.eval(event))
+}
+
+type filterNot struct {
+	operand filterNode
+}
+
+func (node *filterNot) eval(event *Event) interface{} {
+	return !truthy(node.operand.eval(event))
+}
+
+type filterMatch struct {
+	left    filterNode
+	pattern *regexp.Regexp
+	negate  bool
+}
+
+func (node *filterMatch) eval(event *Event) interface{} {
+	value := node.left.eval(event)
+	if value == nil {
+		return node.negate
+	}
+	text, ok := value.(string)
+	if !ok {
+		text = fmt.Sprint(value)
+	}
+	return node.pattern.MatchString(text) != node.negate
+}
+
+type filterCompare struct {
+	operator    string
+	left, right filterNode
+	byLevel     bool
+}
+
+func (node *filterCompare) eval(event *Event) interface{} {
+	left, right := node.left.eval(event), node.right.eval(event)
+	if node.byLevel && node.operator != "==" && node.operator != "!=" {
+		leftRank, leftFound := levelRanks[fmt.Sprint(left)]
+		rightRank, rightFound := levelRanks[fmt.Sprint(right)]
+		if !leftFound || !rightFound {
+			return false
+		}
+		return compareOrdered(node.operator, float64(leftRank), float64(rightRank))
+	}
+	if leftNumber, ok := toFloat(left); ok {
+		if rightNumber, ok := toFloat(right); ok {
+			return compareOrdered(node.operator, leftNumber, rightNumber)
+		}
+	}
+	leftText, leftIsString := left.(string)
+	rightText, rightIsString := right.(string)
+	if leftIsString && rightIsString {
+		switch node.operator {
+		case "==":
+			return leftText == rightText
+		case "!=":
+			return leftText != rightText
+		case ">=":
+			return leftText >= rightText
+		case "<=":
+			return leftText <= rightText
+		case ">":
+			return leftText > rightText
+		case "<":
+			return leftText < rightText
+		}
+	}
+	// 类型不一致时只有!=成立，布尔值只支持相等比较
+	leftBool, leftIsBool := left.(bool)
+	rightBool, rightIsBool := right.(bool)
+	if leftIsBool && rightIsBool {
+		switch node.operator {
+		case "==":
+			return leftBool == rightBool
+		case "!=":
+			return leftBool != rightBool
+		}
+		return false
+	}
+	return node.operator == "!="
+}
+
+func compareOrdered(operator string, left, right float64) bool {
+	switch operator {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case ">=":
+		return left >= right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	case "<":
+		return left < right
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case int8:
+		return float64(number), true
+	case int16:
+		return float64(number), true
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case uint:
+		return float64(number), true
+	case uint8:
+		return float64(number), true
+	case uint16:
+		return float64(number), true
+	case uint32:
+		return float64(number), true
+	case uint64:
+		return float64(number), true
+	case float32:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if number, ok := toFloat(value); ok {
+		return number != 0
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package slog
+
+import (
+	"sync"
+)
+
+// FilterHandler forward events matching Expression to Handler, and the others
+// to Else if it is set. See Filter for the expression syntax.
+type FilterHandler struct {
+	sync.Mutex
+	Expression string
+	Handler    Handler
+	Else       Handler
+	filter     *Filter
+	err        error
+}
+
+// Initialize compile the expression and initialize the wrapped handlers
+func (handler *FilterHandler) Initialize() error {
+	handler.Lock()
+	defer handler.Unlock()
+	if err := handler.compile(); err != nil {
+		return err
+	}
+	if err := initializeHandler(handler.Handler); err != nil {
+		return err
+	}
+	return initializeHandler(handler.Else)
+}
+
+// Close close the wrapped handlers
+func (handler *FilterHandler) Close() error {
+	err := closeHandler(handler.Handler)
+	if elseErr := closeHandler(handler.Else); err == nil {
+		err = elseErr
+	}
+	return err
+}
+
+func (handler *FilterHandler) compile() error {
+	if handler.filter == nil && handler.err == nil {
+		handler.filter, handler.err = CompileFilter(handler.Expression)
+	}
+	return handler.err
+}
+
+func (handler *FilterHandler) Handle(event *Event) {
+	handler.Lock()
+	err := handler.compile()
+	handler.Unlock()
+	if err != nil {
+		reportError("filter", event, &handleError{"filter event fail", err})
+	} else if handler.filter.Match(event) {
+		handler.Handler.Handle(event)
+	} else if handler.Else != nil {
+		handler.Else.Handle(event)
+	}
+}
+
+// Transient return true if both wrapped handlers are transient
+func (handler *FilterHandler) Transient() bool {
+	return isTransient(handler.Handler) && isTransient(handler.Else)
+}
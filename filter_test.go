@@ -0,0 +1,116 @@
+package slog
+
+import (
+	"testing"
+)
+
+func TestCompileFilterFail(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"level >=",
+		"(level == warn",
+		"message =~ \"(\"",
+		"message =~ level",
+		"unknown == 1",
+		"fields.foo == \"bar",
+		"level # warn",
+	} {
+		if _, err := CompileFilter(expression); err == nil {
+			t.Errorf("unexpected success: %q\n", expression)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	globalFields = map[string]interface{}{"region": "cn"}
+	defer func() { globalFields = make(map[string]interface{}) }()
+	event := &Event{
+		Level:   warnLevel,
+		Message: "connect timeout",
+		Session: Session{"tenant": "acme"},
+		Fields: Fields{
+			"retry":   3,
+			"ok":      false,
+			"request": map[string]interface{}{"method": "GET"},
+		},
+		Caller: Caller{
+			Package: "github.com/yangchenxing/go-slog",
+			File:    "filter_test.go",
+			Func:    "TestFilterMatch",
+			Line:    42,
+		},
+	}
+	cases := map[string]bool{
+		"level >= warn":     true,
+		"level > warn":      false,
+		"level < \"error\"": true,
+		"level == warn && message =~ \"timeout\"":     true,
+		"message !~ \"timeout\"":                      false,
+		"session.tenant == \"acme\"":                  true,
+		"fields.tenant == \"acme\"":                   false,
+		"fields.retry >= 3 && fields.retry < 3.5":     true,
+		"fields.ok == false":                          true,
+		"!fields.ok":                                  true,
+		"fields.missing":                              false,
+		"fields.request.method == \"GET\"":            true,
+		"global.region == \"cn\"":                     true,
+		"caller.file == \"filter_test.go\"":           true,
+		"caller.line == 42":                           true,
+		"caller.package =~ \"go-slog$\"":              true,
+		"caller.func != \"TestFilterMatch\"":          false,
+		"level == debug || (level == warn && !false)": true,
+		"fields.retry == \"3\"":                       false,
+	}
+	for expression, expected := range cases {
+		filter, err := CompileFilter(expression)
+		if err != nil {
+			t.Errorf("compile %q fail: %s\n", expression, err.Error())
+		} else if filter.Match(event) != expected {
+			t.Errorf("unexpected result of %q: %v\n", expression, !expected)
+		}
+	}
+}
+
+func TestFilterHandler(t *testing.T) {
+	matched := new(receiveHandler)
+	unmatched := new(receiveHandler)
+	handler := &FilterHandler{
+		Expression: "level >= warn && fields.tenant == \"acme\"",
+		Handler:    matched,
+		Else:       unmatched,
+	}
+	if err := handler.Initialize(); err != nil {
+		t.Error("initialize fail:", err.Error())
+		return
+	}
+	for _, level := range []string{infoLevel, warnLevel, errorLevel} {
+		event := newEvent(1, nil).WithField("tenant", "acme")
+		event.Level = level
+		handler.Handle(event)
+	}
+	if len(matched.events) != 2 || len(unmatched.events) != 1 {
+		t.Error("unexpected events:", matched.events, unmatched.events)
+	}
+}
+
+func TestLoadConfigFilterFail(t *testing.T) {
+	handlers = nil
+	err := LoadConfig(Config{
+		Handlers: []HandlerConfig{
+			{
+				Levels: []string{infoLevel},
+				Handler: &SamplingHandler{
+					Handler: &FilterHandler{
+						Expression: "level >=",
+						Handler:    new(receiveHandler),
+					},
+				},
+			},
+		},
+	})
+	if err == nil {
+		t.Error("unexpected success")
+	} else if handlers != nil {
+		t.Error("unexpected handlers:", handlers)
+	}
+}
@@ -0,0 +1,168 @@
+package slog
+
+import (
+	"sync"
+	"time"
+)
+
+// sessionBuffer keep its session, so that the id of the buffer is not reused
+type sessionBuffer struct {
+	session  Session
+	events   []*Event
+	lastSeen time.Time
+}
+
+// FingersCrossedHandler buffer events below TriggerLevel for each session, and
+// flush them in order to Handler when an event at or above TriggerLevel arrives
+// in the same session. Each buffer keeps at most MaxEvents events not older than
+// MaxAge, and is discarded when the session ends or has been idle for MaxAge.
+// Events without a session or of GlobalSession are not related to each other,
+// so they are forwarded directly, as events of unknown levels and all events
+// after Close.
+type FingersCrossedHandler struct {
+	sync.Mutex
+	Handler      Handler
+	TriggerLevel string
+	MaxEvents    int
+	MaxAge       time.Duration
+	buffers      map[uintptr]*sessionBuffer
+	triggerRank  int
+	initialized  bool
+	closed       bool
+	stop         chan struct{}
+	removeHook   func()
+}
+
+// Initialize initialize the wrapped handler
+func (handler *FingersCrossedHandler) Initialize() error {
+	return initializeHandler(handler.Handler)
+}
+
+// Close stop discarding idle buffers, drop buffered events and close the wrapped handler
+func (handler *FingersCrossedHandler) Close() error {
+	handler.Lock()
+	if handler.initialized && !handler.closed {
+		close(handler.stop)
+		handler.removeHook()
+		for _, buffer := range handler.buffers {
+			droppedEventsTotal.add(float64(len(buffer.events)), "FingersCrossedHandler")
+		}
+		handler.buffers = nil
+	}
+	handler.closed = true
+	handler.Unlock()
+	return closeHandler(handler.Handler)
+}
+
+func (handler *FingersCrossedHandler) Handle(event *Event) {
+	if flushed, forward := handler.buffer(event); forward {
+		for _, buffered := range flushed {
+			handler.Handler.Handle(buffered)
+		}
+		handler.Handler.Handle(event)
+	}
+}
+
+// buffer keep event if it is below trigger level, otherwise return the buffered
+// events of its session which should be handled before it
+func (handler *FingersCrossedHandler) buffer(event *Event) ([]*Event, bool) {
+	handler.Lock()
+	defer handler.Unlock()
+	if handler.closed {
+		return nil, true
+	}
+	if !handler.initialized {
+		handler.initialize()
+	}
+	id := event.sessionID()
+	rank, found := levelRanks[event.Level]
+	if !found || id == 0 || id == sessionID(GlobalSession) {
+		return nil, true
+	}
+	now := time.Now()
+	buffer := handler.buffers[id]
+	if rank >= handler.triggerRank {
+		if buffer == nil {
+			return nil, true
+		}
+		delete(handler.buffers, id)
+		return handler.fresh(buffer.events, now), true
+	}
+	if buffer == nil {
+		buffer = &sessionBuffer{session: event.origin(), events: make([]*Event, 0, 16)}
+		handler.buffers[id] = buffer
+	}
+	buffer.events = append(handler.fresh(buffer.events, now), event)
+	if len(buffer.events) > handler.MaxEvents {
+		buffer.events = buffer.events[len(buffer.events)-handler.MaxEvents:]
+	}
+	buffer.lastSeen = now
+	return nil, false
+}
+
+// fresh drop events older than MaxAge from the front of events
+func (handler *FingersCrossedHandler) fresh(events []*Event, now time.Time) []*Event {
+	i := 0
+	for i < len(events) && now.Sub(events[i].Timestamp) > handler.MaxAge {
+		i++
+	}
+	return events[i:]
+}
+
+func (handler *FingersCrossedHandler) initialize() {
+	if handler.TriggerLevel == "" {
+		handler.TriggerLevel = errorLevel
+	}
+	if handler.MaxEvents <= 0 {
+		handler.MaxEvents = 100
+	}
+	if handler.MaxAge <= 0 {
+		handler.MaxAge = time.Minute
+	}
+	if rank, found := levelRanks[handler.TriggerLevel]; found {
+		handler.triggerRank = rank
+	} else {
+		handler.triggerRank = levelRanks[errorLevel]
+	}
+	handler.buffers = make(map[uintptr]*sessionBuffer)
+	handler.initialized = true
+	handler.stop = make(chan struct{})
+	handler.removeHook = onSessionEnd(handler.discard)
+	go handler.expire(handler.stop)
+}
+
+// discard drop buffered events of session
+func (handler *FingersCrossedHandler) discard(session Session) {
+	handler.Lock()
+	defer handler.Unlock()
+	id := sessionID(session)
+	if buffer := handler.buffers[id]; buffer != nil {
+		droppedEventsTotal.add(float64(len(buffer.events)), "FingersCrossedHandler")
+		delete(handler.buffers, id)
+	}
+}
+
+func (handler *FingersCrossedHandler) expire(stop chan struct{}) {
+	ticker := time.NewTicker(handler.MaxAge)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			handler.discardIdle(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// discardIdle drop buffers of sessions idle for MaxAge
+func (handler *FingersCrossedHandler) discardIdle(now time.Time) {
+	handler.Lock()
+	defer handler.Unlock()
+	for id, buffer := range handler.buffers {
+		if now.Sub(buffer.lastSeen) > handler.MaxAge {
+			droppedEventsTotal.add(float64(len(buffer.events)), "FingersCrossedHandler")
+			delete(handler.buffers, id)
+		}
+	}
+}
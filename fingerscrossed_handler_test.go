@@ -0,0 +1,127 @@
+package slog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFingersCrossedHandler(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &FingersCrossedHandler{
+		Handler:   receiver,
+		MaxEvents: 2,
+	}
+	failed, succeeded := NewSession(), NewSession()
+	for _, session := range []Session{failed, succeeded} {
+		for _, message := range []string{"1", "2", "3"} {
+			event := newEvent(1, session)
+			event.Level = debugLevel
+			event.Message = message
+			handler.Handle(event)
+		}
+	}
+	if len(receiver.events) != 0 {
+		t.Error("unexpected events:", receiver.events)
+		return
+	}
+	event := newEvent(1, failed)
+	event.Level = errorLevel
+	event.Message = "fail"
+	handler.Handle(event)
+	if len(receiver.events) != 3 || receiver.events[0].Message != "2" ||
+		receiver.events[1].Message != "3" || receiver.events[2].Message != "fail" {
+		t.Error("unexpected events:", receiver.events)
+	}
+	succeeded.End()
+	if len(handler.buffers) != 0 {
+		t.Error("unexpected buffers:", handler.buffers)
+	}
+}
+
+func TestFingersCrossedHandlerMaxAge(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &FingersCrossedHandler{
+		Handler: receiver,
+		MaxAge:  time.Minute,
+	}
+	session := NewSession()
+	event := newEvent(1, session)
+	event.Level = debugLevel
+	event.Timestamp = time.Now().Add(-time.Hour)
+	handler.Handle(event)
+	event = newEvent(1, session)
+	event.Level = infoLevel
+	handler.Handle(event)
+	event = newEvent(1, session)
+	event.Level = fatalLevel
+	handler.Handle(event)
+	if len(receiver.events) != 2 || receiver.events[0].Level != infoLevel {
+		t.Error("unexpected events:", receiver.events)
+	}
+	event = newEvent(1, session)
+	event.Level = debugLevel
+	handler.Handle(event)
+	handler.discardIdle(time.Now().Add(time.Hour))
+	if len(handler.buffers) != 0 {
+		t.Error("unexpected buffers:", handler.buffers)
+	}
+}
+
+func TestFingersCrossedHandlerKeepSession(t *testing.T) {
+	handler := &FingersCrossedHandler{Handler: new(receiveHandler)}
+	session := NewSession().WithField("password", "secret")
+	defer session.End()
+	redactor, _ := newRedactor([]RedactionRule{{Keys: []string{"password"}}}, "")
+	event := newEvent(1, session)
+	event.Level = debugLevel
+	handler.Handle(redactor.redact(event))
+	buffer := handler.buffers[sessionID(session)]
+	if buffer == nil || sessionID(buffer.session) != sessionID(session) {
+		t.Errorf("session of buffer not kept: %v", handler.buffers)
+	}
+}
+
+func TestFingersCrossedHandlerClose(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &FingersCrossedHandler{Handler: receiver}
+	hooks := len(sessionEndHooks)
+	session := NewSession()
+	event := newEvent(1, session)
+	event.Level = debugLevel
+	handler.Handle(event)
+	if len(sessionEndHooks) != hooks+1 {
+		t.Fatal("session end hook not registered")
+	}
+	handler.Close()
+	if len(sessionEndHooks) != hooks || len(handler.buffers) != 0 {
+		t.Error("session end hook or buffers kept after Close")
+	}
+	session.End()
+	handler.Handle(event)
+	if len(receiver.events) != 1 {
+		t.Error("event not forwarded after Close:", receiver.events)
+	}
+}
+
+func TestFingersCrossedHandlerWithoutSession(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &FingersCrossedHandler{Handler: receiver}
+	for _, session := range []Session{nil, GlobalSession} {
+		event := newEvent(1, session)
+		event.Level = debugLevel
+		event.Message = "debug"
+		handler.Handle(event)
+	}
+	event := newEvent(1, nil)
+	event.Level = errorLevel
+	event.Message = "fail"
+	handler.Handle(event)
+	if len(receiver.events) != 3 || receiver.events[0].Message != "debug" ||
+		receiver.events[1].Message != "debug" || receiver.events[2].Message != "fail" {
+		t.Error("unexpected events:", receiver.events)
+	}
+	if len(handler.buffers) != 0 {
+		t.Error("unexpected buffers:", handler.buffers)
+	}
+	handler.Close()
+}
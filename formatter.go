@@ -2,12 +2,10 @@ package slog
 
 import (
 	"bytes"
-	"fmt"
 	"strings"
+	"sync"
 	"time"
 
-	"sort"
-
 	"github.com/yangchenxing/go-string-mapformatter"
 )
 
@@ -19,10 +17,14 @@ type MultiEventFormatter interface {
 	FormatEvents([]*Event) ([]byte, error)
 }
 
+var fieldsPool = sync.Pool{New: func() interface{} { return make(map[string]interface{}) }}
+
 type PlainTextFormatter struct {
 	TimestampFormat                     string
 	EventFormat                         string
 	SortFields                          bool
+	TraceConvention                     string
+	Limits                              Limits
 	levelANSIColorFuncs                 map[string]func(...interface{}) string
 	needEventFieldsSpaceSeperatedText   bool
 	needEventFieldsCommaSeperatedText   bool
@@ -41,8 +43,8 @@ func (formatter *PlainTextFormatter) FormatEvents(events []*Event) ([]byte, erro
 	}
 	var buffer bytes.Buffer
 	for _, event := range events {
-		fields := formatter.fieldify(event)
-		buffer.Write([]byte(mapformatter.Format(formatter.EventFormat, fields)))
+		content, _ := formatter.Limits.format(event, formatter.render)
+		buffer.Write(content)
 		buffer.WriteRune('\n')
 	}
 	return buffer.Bytes(), nil
@@ -52,57 +54,77 @@ func (formatter *PlainTextFormatter) FormatEvent(event *Event) ([]byte, error) {
 	if !formatter.initialized {
 		formatter.initialize()
 	}
-	fields := formatter.fieldify(event)
-	content := mapformatter.Format(formatter.EventFormat, fields)
-	return []byte(content), nil
+	return formatter.Limits.format(event, formatter.render)
+}
+
+func (formatter *PlainTextFormatter) render(event *Event) ([]byte, error) {
+	fields := fieldsPool.Get().(map[string]interface{})
+	formatter.fieldifyInto(event, fields)
+	content := []byte(mapformatter.Format(formatter.EventFormat, fields))
+	if len(fields) <= maxPooledFields {
+		for key := range fields {
+			delete(fields, key)
+		}
+		fieldsPool.Put(fields)
+	}
+	return content, nil
 }
 
 func (formatter *PlainTextFormatter) fieldify(event *Event) map[string]interface{} {
-	fields := event.Fieldify(formatter.TimestampFormat)
+	fields := make(map[string]interface{})
+	formatter.fieldifyInto(event, fields)
+	return fields
+}
+
+func (formatter *PlainTextFormatter) fieldifyInto(event *Event, fields map[string]interface{}) {
+	event.fieldifyInto(fields, formatter.TimestampFormat)
+	renameTraceFields(fields, formatter.TraceConvention)
+	// 连接文本中的追踪字段同样改名
+	names := traceConventions[formatter.TraceConvention]
 	// 补充事件自定义字段连接文本
 	if formatter.needEventFieldsSpaceSeperatedText {
-		fields[".event_fields_space_seperated_text"] = JoinFields(event.Fields, "=", " ", formatter.SortFields)
+		fields[".event_fields_space_seperated_text"] = joinEventFields(event, names, "=", " ", formatter.SortFields)
 	}
 	if formatter.needEventFieldsCommaSeperatedText {
-		fields[".event_fields_comma_seperated_text"] = JoinFields(event.Fields, "=", ",", formatter.SortFields)
+		fields[".event_fields_comma_seperated_text"] = joinEventFields(event, names, "=", ",", formatter.SortFields)
 	}
 	// 补充会话自定义字段连接文本
 	if formatter.needSessionFieldsSpaceSeperatedText {
-		fields[".session_fields_space_seperated_text"] = JoinFields(event.Session, "=", " ", formatter.SortFields)
+		fields[".session_fields_space_seperated_text"] = joinOrderedFields(event.Session, sessionOrder(event.sessionID()), names, "=", " ", formatter.SortFields)
 	}
 	if formatter.needSessionFieldsCommaSeperatedText {
-		fields[".session_fields_comma_seperated_text"] = JoinFields(event.Session, "=", ",", formatter.SortFields)
+		fields[".session_fields_comma_seperated_text"] = joinOrderedFields(event.Session, sessionOrder(event.sessionID()), names, "=", ",", formatter.SortFields)
 	}
 	// 补充全局自定义字段连接文本
 	if formatter.needSessionFieldsSpaceSeperatedText {
-		fields[".global_fields_space_seperated_text"] = JoinFields(globalFields, "=", " ", formatter.SortFields)
+		fields[".global_fields_space_seperated_text"] = joinOrderedFields(event.globalView(), globalOrder, names, "=", " ", formatter.SortFields)
 	}
 	if formatter.needSessionFieldsCommaSeperatedText {
-		fields[".global_fields_comma_seperated_text"] = JoinFields(globalFields, "=", ",", formatter.SortFields)
+		fields[".global_fields_comma_seperated_text"] = joinOrderedFields(event.globalView(), globalOrder, names, "=", ",", formatter.SortFields)
 	}
 	// 补充全字段连接文本
 	if formatter.needAllFieldsSpaceSeperatedText {
 		parts := make([]string, 0, 3)
-		if text := JoinFields(globalFields, "=", " ", formatter.SortFields); text != "" {
+		if text := joinOrderedFields(event.globalView(), globalOrder, names, "=", " ", formatter.SortFields); text != "" {
 			parts = append(parts, text)
 		}
-		if text := JoinFields(event.Session, "=", " ", formatter.SortFields); text != "" {
+		if text := joinOrderedFields(event.Session, sessionOrder(event.sessionID()), names, "=", " ", formatter.SortFields); text != "" {
 			parts = append(parts, text)
 		}
-		if text := JoinFields(event.Fields, "=", " ", formatter.SortFields); text != "" {
+		if text := joinEventFields(event, names, "=", " ", formatter.SortFields); text != "" {
 			parts = append(parts, text)
 		}
 		fields[".all_fields_space_seperated_text"] = strings.Join(parts, " ")
 	}
 	if formatter.needAllFieldsCommaSeperatedText {
 		parts := make([]string, 0, 3)
-		if text := JoinFields(globalFields, "=", ",", formatter.SortFields); text != "" {
+		if text := joinOrderedFields(event.globalView(), globalOrder, names, "=", ",", formatter.SortFields); text != "" {
 			parts = append(parts, text)
 		}
-		if text := JoinFields(event.Session, "=", ",", formatter.SortFields); text != "" {
+		if text := joinOrderedFields(event.Session, sessionOrder(event.sessionID()), names, "=", ",", formatter.SortFields); text != "" {
 			parts = append(parts, text)
 		}
-		if text := JoinFields(event.Fields, "=", ",", formatter.SortFields); text != "" {
+		if text := joinEventFields(event, names, "=", ",", formatter.SortFields); text != "" {
 			parts = append(parts, text)
 		}
 		fields[".all_fields_comma_seperated_text"] = strings.Join(parts, ",")
@@ -118,24 +140,38 @@ func (formatter *PlainTextFormatter) fieldify(event *Event) map[string]interface
 	fields["caller.func"] = event.Caller.Func
 	fields["caller.line"] = event.Caller.Line
 	fields["caller.package"] = event.Caller.Package
-	return fields
 }
 
 func JoinFields(fields map[string]interface{}, equal, seperator string, order bool) string {
-	strs := make([]string, len(fields))
-	i := 0
-	for key, value := range fields {
-		if s, ok := value.(string); ok {
-			strs[i] = fmt.Sprintf("%s%s%q", key, equal, s)
-		} else {
-			strs[i] = fmt.Sprintf("%s%s%v", key, equal, value)
-		}
-		i++
+	if len(fields) == 0 {
+		return ""
+	}
+	joiner := joinerPool.Get().(*fieldsJoiner)
+	defer joinerPool.Put(joiner)
+	return string(joiner.join(fields, equal, seperator, order))
+}
+
+// joinOrderedFields join fields as JoinFields does, the keys of keyOrder first
+// unless order is true, and keys in names renamed
+func joinOrderedFields(fields map[string]interface{}, keyOrder []string, names map[string]string, equal, seperator string, order bool) string {
+	if len(fields) == 0 {
+		return ""
 	}
-	if order {
-		sort.Sort(sort.StringSlice(strs))
+	joiner := joinerPool.Get().(*fieldsJoiner)
+	defer joinerPool.Put(joiner)
+	return string(joiner.joinOrdered(fields, keyOrder, names, equal, seperator, order))
+}
+
+// joinEventFields join event.Fields and typed fields of event as JoinFields
+// does, in the order they are added unless order is true, and keys in names
+// renamed
+func joinEventFields(event *Event, names map[string]string, equal, seperator string, order bool) string {
+	if len(event.Fields) == 0 && len(event.typed) == 0 {
+		return ""
 	}
-	return strings.Join(strs, seperator)
+	joiner := joinerPool.Get().(*fieldsJoiner)
+	defer joinerPool.Put(joiner)
+	return string(joiner.joinEvent(event, names, equal, seperator, order))
 }
 
 func (formatter *PlainTextFormatter) initialize() {
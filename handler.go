@@ -1,14 +1,14 @@
 package slog
 
 import (
-	"encoding/json"
-	"fmt"
-	"os"
-	"time"
+	"reflect"
+	"sync"
 )
 
 var (
+	handlersLock   sync.RWMutex
 	handlers       map[string][]Handler
+	panicFallback  bool
 	defaultHandler = &PlainTextHandler{
 		Formatter: &PlainTextFormatter{
 			EventFormat: "%(level|s) [%(timestamp|s)] %(message|s) [%(.all_fields_space_seperated_text|s)]",
@@ -28,7 +28,121 @@ type Handler interface {
 	Handle(*Event)
 }
 
+// Initializer is implemented by handlers which need validation or preparation
+// when the config is loaded, wrapping handlers should initialize the wrapped ones.
+type Initializer interface {
+	Initialize() error
+}
+
+// TransientHandler is implemented by handlers which neither keep events nor
+// pass them to handlers keeping them after Handle returns, events handled only
+// by transient handlers are put back to a pool and reused. Handlers which keep
+// events should keep their clones.
+type TransientHandler interface {
+	Handler
+	Transient() bool
+}
+
+func isTransient(handler Handler) bool {
+	if handler == nil {
+		return true
+	}
+	transient, ok := handler.(TransientHandler)
+	return ok && transient.Transient()
+}
+
+// CheckedHandler is implemented by handlers which can report handling errors
+type CheckedHandler interface {
+	Handler
+	TryHandle(*Event) error
+}
+
+// handleError is the error reported by TryHandle, stage describes where it fails
+type handleError struct {
+	stage string
+	cause error
+}
+
+func (err *handleError) Error() string {
+	return err.stage + ": " + err.cause.Error()
+}
+
+func (err *handleError) Unwrap() error {
+	return err.cause
+}
+
+func initializeHandler(handler Handler) error {
+	if initializer, ok := handler.(Initializer); ok {
+		return initializer.Initialize()
+	}
+	return nil
+}
+
+// Closer is implemented by handlers running goroutines or holding resources,
+// which are released by Close. LoadConfig close the handlers it replaces, and
+// handlers replaced by SetHandlers should be closed by the caller. Wrapping
+// handlers should close the wrapped ones.
+type Closer interface {
+	Close() error
+}
+
+func closeHandler(handler Handler) error {
+	if closer, ok := handler.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// closeReplacedHandlers close the handlers of old which are not in current
+func closeReplacedHandlers(old, current map[string][]Handler) {
+	kept := make(map[Handler]bool)
+	for _, levelHandlers := range current {
+		for _, handler := range levelHandlers {
+			if isComparable(handler) {
+				kept[handler] = true
+			}
+		}
+	}
+	for _, levelHandlers := range old {
+		for _, handler := range levelHandlers {
+			if isComparable(handler) && !kept[handler] {
+				// 同一处理器可能注册在多个级别, 只关闭一次
+				kept[handler] = true
+				closeHandler(handler)
+			}
+		}
+	}
+}
+
+func isComparable(handler Handler) bool {
+	return handler != nil && reflect.TypeOf(handler).Comparable()
+}
+
+// Enabled report whether any handler is registered for level, events of
+// disabled levels are dropped before their callers and messages are built
+func Enabled(level string) bool {
+	handlersLock.RLock()
+	defer handlersLock.RUnlock()
+	return len(handlersOf(level)) > 0
+}
+
+// handlersOf return the handlers of level, panic events are handled by error
+// handlers if there is no panic handler and panicFallback is set, handlersLock
+// must be held
+func handlersOf(level string) []Handler {
+	levelHandlers := handlers
+	if levelHandlers == nil {
+		levelHandlers = defaultHandlers
+	}
+	if level == panicLevel && panicFallback && len(levelHandlers[level]) == 0 {
+		return levelHandlers[errorLevel]
+	}
+	return levelHandlers[level]
+}
+
 func AddHandler(levels []string, handler Handler) {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
 	if handlers == nil {
 		handlers = make(map[string][]Handler)
 	}
@@ -41,21 +155,55 @@ func AddHandler(levels []string, handler Handler) {
 	}
 }
 
+// SetHandlers replace all handlers of each level and return the previous ones,
+// nil means the default handlers
+func SetHandlers(newHandlers map[string][]Handler) map[string][]Handler {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	oldHandlers := handlers
+	handlers = newHandlers
+	return oldHandlers
+}
+
 type JsonHandler struct {
 	TimestampFormat string
+	TraceConvention string
+	Limits          Limits
 	Writer          Writer
 }
 
 func (handler *JsonHandler) Handle(event *Event) {
-	timestampFormat := handler.TimestampFormat
-	if timestampFormat == "" {
-		timestampFormat = time.RFC3339
+	if err := handler.TryHandle(event); err != nil {
+		reportError("json", event, err)
 	}
-	if content, err := json.Marshal(event.Fieldify(handler.TimestampFormat)); err != nil {
-		fmt.Fprintf(os.Stderr, "marshal json fail: event=%v, error=%q\n", event, err.Error())
-	} else if err := handler.Writer.Write(content); err != nil {
-		fmt.Fprintf(os.Stderr, "write json fail: event=%v, error=%q\n", event, err.Error())
+}
+
+// Transient return true as events are written before Handle returns
+func (handler *JsonHandler) Transient() bool {
+	return true
+}
+
+// Probe probe the writer, see Prober
+func (handler *JsonHandler) Probe() error {
+	return tryProbe(handler.Writer)
+}
+
+func (handler *JsonHandler) writesTypedFields() bool {
+	return !handler.Limits.enabled()
+}
+
+func (handler *JsonHandler) TryHandle(event *Event) error {
+	encoder := getJSONEncoder()
+	defer putJSONEncoder(encoder)
+	content, err := handler.Limits.format(event, func(event *Event) ([]byte, error) {
+		return encoder.encode(event, handler.TimestampFormat, handler.TraceConvention)
+	})
+	if err != nil {
+		return &handleError{"marshal json fail", err}
+	} else if err := writeContent(handler.Writer, content); err != nil {
+		return &handleError{"write json fail", err}
 	}
+	return nil
 }
 
 type PlainTextHandler struct {
@@ -64,8 +212,29 @@ type PlainTextHandler struct {
 }
 
 func (handler *PlainTextHandler) Handle(event *Event) {
+	if err := handler.TryHandle(event); err != nil {
+		reportError("plaintext", event, err)
+	}
+}
+
+// Transient return true as events are written before Handle returns
+func (handler *PlainTextHandler) Transient() bool {
+	return true
+}
+
+// Probe probe the writer, see Prober
+func (handler *PlainTextHandler) Probe() error {
+	return tryProbe(handler.Writer)
+}
+
+func (handler *PlainTextHandler) writesTypedFields() bool {
+	return handler.Formatter != nil && !handler.Formatter.Limits.enabled()
+}
+
+func (handler *PlainTextHandler) TryHandle(event *Event) error {
 	content, _ := handler.Formatter.FormatEvent(event)
-	if err := handler.Writer.Write(content); err != nil {
-		fmt.Fprintf(os.Stderr, "write text fail: event=%v, error=%q\n", event, err.Error())
+	if err := writeContent(handler.Writer, content); err != nil {
+		return &handleError{"write text fail", err}
 	}
+	return nil
 }
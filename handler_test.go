@@ -172,3 +172,38 @@ func TestPlainTextHandlerFail(t *testing.T) {
 		return
 	}
 }
+
+type closingHandler struct {
+	receiveHandler
+	closed int
+}
+
+func (handler *closingHandler) Close() error {
+	handler.closed++
+	return nil
+}
+
+func TestLoadConfigCloseReplaced(t *testing.T) {
+	defer SetHandlers(SetHandlers(map[string][]Handler{}))
+	replaced, kept := new(closingHandler), new(closingHandler)
+	wrapped := new(closingHandler)
+	if err := LoadConfig(Config{Handlers: []HandlerConfig{
+		{Levels: []string{"info", "warn"}, Handler: replaced},
+		{Levels: []string{"info"}, Handler: kept},
+		{Levels: []string{"error"}, Handler: &FilterHandler{Expression: "true", Handler: wrapped}},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if levelHandlers := handlers["info"]; len(levelHandlers) != 2 {
+		t.Errorf("unexpected info handlers: %v", levelHandlers)
+	}
+	if err := LoadConfig(Config{Handlers: []HandlerConfig{{Levels: []string{"info"}, Handler: kept}}}); err != nil {
+		t.Fatal(err)
+	}
+	if replaced.closed != 1 || kept.closed != 0 || wrapped.closed != 1 {
+		t.Errorf("unexpected closes: %d %d %d", replaced.closed, kept.closed, wrapped.closed)
+	}
+	if levelHandlers := handlers["info"]; len(levelHandlers) != 1 || levelHandlers[0] != kept {
+		t.Errorf("handlers not replaced: %v", levelHandlers)
+	}
+}
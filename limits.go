@@ -0,0 +1,345 @@
+package slog
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"unicode/utf8"
+)
+
+// TruncatedKey is the field listing what is truncated or dropped by Limits,
+// such as "message", "fields.body" or "session.user"
+const TruncatedKey = "_truncated"
+
+const depthMarker = "[truncated]"
+
+// Limits restrict the size of formatted events, zero means no limit.
+// MaxMessageBytes and MaxValueBytes cut the message and string values at any
+// depth, MaxFields keep the first fields of the merged global, session and
+// event fields in output order and list the others in TruncatedKey with their
+// scopes, MaxDepth replace containers nested deeper than it, and if the
+// formatted event still exceeds MaxEventBytes the largest event fields are
+// dropped, then session fields and global fields, then the message is cut.
+// The timestamp, level, caller and the TruncatedKey field are always kept, so
+// an event exceeds MaxEventBytes only if they alone do.
+type Limits struct {
+	MaxMessageBytes int
+	MaxValueBytes   int
+	MaxFields       int
+	MaxEventBytes   int
+	MaxDepth        int
+}
+
+func (limits *Limits) enabled() bool {
+	return limits.MaxMessageBytes > 0 || limits.MaxValueBytes > 0 || limits.MaxFields > 0 ||
+		limits.MaxEventBytes > 0 || limits.MaxDepth > 0
+}
+
+// cutString cut text to at most size bytes without splitting characters
+func cutString(text string, size int) string {
+	if size < 0 {
+		size = 0
+	}
+	if len(text) <= size {
+		return text
+	}
+	for size > 0 && !utf8.RuneStart(text[size]) {
+		size--
+	}
+	return text[:size]
+}
+
+func (limits *Limits) limitValue(value interface{}, depth int) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		if limits.MaxValueBytes > 0 && len(v) > limits.MaxValueBytes {
+			return cutString(v, limits.MaxValueBytes), true
+		}
+		return value, false
+	case ErrorChain:
+		return limits.limitErrorChain(v, depth)
+	case error, fmt.Stringer:
+		return value, false
+	}
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth && isContainer(value) {
+		return depthMarker, true
+	}
+	if depth > maxRewriteDepth || (limits.MaxValueBytes <= 0 && limits.MaxDepth <= 0) {
+		return value, false
+	}
+	return rewriteContainer(value, depth, limits.limitField, limits.limitValue)
+}
+
+// limitErrorChain cut the messages of chain as string values, and drop the
+// stack traces nested deeper than MaxDepth, which are at depth+2
+func (limits *Limits) limitErrorChain(chain ErrorChain, depth int) (interface{}, bool) {
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return depthMarker, true
+	}
+	limited, changed := chain.rewriteMessages(func(message string) string {
+		if limits.MaxValueBytes > 0 {
+			return cutString(message, limits.MaxValueBytes)
+		}
+		return message
+	})
+	if limits.MaxDepth <= 0 || depth+2 <= limits.MaxDepth {
+		return limited, changed
+	}
+	var copied ErrorChain
+	for i, cause := range limited {
+		if len(cause.Stack) == 0 {
+			continue
+		}
+		if copied == nil {
+			copied = append(ErrorChain(nil), limited...)
+		}
+		copied[i].Stack = nil
+	}
+	if copied == nil {
+		return limited, changed
+	}
+	return copied, true
+}
+
+func (limits *Limits) limitField(key string, value interface{}, depth int) (interface{}, bool) {
+	return limits.limitValue(value, depth)
+}
+
+func isContainer(value interface{}) bool {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct:
+		return true
+	case reflect.Slice, reflect.Array:
+		return rv.Type().Elem().Kind() != reflect.Uint8
+	}
+	return false
+}
+
+// limitFields return the limited copy of fields without the keys in dropped,
+// or fields itself if nothing is changed, and the names of truncated values
+// prefixed with prefix
+func (limits *Limits) limitFields(fields map[string]interface{}, prefix string, dropped map[string]bool) (map[string]interface{}, []string, bool) {
+	keys := make([]string, 0, len(fields))
+	removed := false
+	for key := range fields {
+		if dropped[prefix+key] {
+			removed = true
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var truncated []string
+	limited := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value, changed := limits.limitValue(fields[key], 1)
+		if changed {
+			truncated = append(truncated, prefix+key)
+		}
+		limited[key] = value
+	}
+	if !removed && len(truncated) == 0 {
+		return fields, nil, false
+	}
+	return limited, truncated, true
+}
+
+// overflowFields return the names of the fields beyond MaxFields of the merged
+// fields in output order, prefixed with their scopes as "fields.", "session."
+// and "global.", and the names to drop including the shadowed fields
+func (limits *Limits) overflowFields(event *Event) ([]string, map[string]bool) {
+	if limits.MaxFields <= 0 {
+		return nil, nil
+	}
+	var names []string
+	var dropped map[string]bool
+	count := 0
+	event.rangeOrderedFields(func(key string, value interface{}, index int) bool {
+		if count++; count <= limits.MaxFields {
+			return true
+		}
+		scope := globalScope
+		if index >= 0 || event.hasField(key) {
+			scope = eventScope
+		} else if _, found := event.Session[key]; found {
+			scope = sessionScope
+		}
+		names = append(names, scopePrefixes[scope]+key)
+		if dropped == nil {
+			dropped = make(map[string]bool)
+		}
+		// 同名的低层字段一并丢弃, 否则它们不再被覆盖而被输出
+		for ; scope <= globalScope; scope++ {
+			dropped[scopePrefixes[scope]+key] = true
+		}
+		return true
+	})
+	return names, dropped
+}
+
+// truncate return the limited copy of event with TruncatedKey field, or event
+// itself if nothing is truncated
+func (limits *Limits) truncate(event *Event) *Event {
+	limited := *event
+	var truncated []string
+	if limits.MaxMessageBytes > 0 && len(event.Message) > limits.MaxMessageBytes {
+		limited.Message = cutString(event.Message, limits.MaxMessageBytes)
+		truncated = append(truncated, "message")
+	}
+	overflow, dropped := limits.overflowFields(event)
+	truncated = append(truncated, overflow...)
+	ownFields := event.Fields
+	if len(overflow) > 0 && len(event.typed) > 0 {
+		// 类型化字段与原事件共享, 合并到复制的字段后再丢弃
+		ownFields = copyFields(event.Fields)
+		for i := range event.typed {
+			ownFields[event.typed[i].Key] = event.typed[i].Value()
+		}
+		limited.typed = nil
+	}
+	fields, fieldsTruncated, _ := limits.limitFields(ownFields, scopePrefixes[eventScope], dropped)
+	session, sessionTruncated, sessionChanged := limits.limitFields(event.Session, scopePrefixes[sessionScope], dropped)
+	if sessionChanged {
+		limited.Session = session
+		limited.originSession = event.origin()
+	}
+	globals, globalsTruncated, globalsChanged := limits.limitFields(event.globalView(), scopePrefixes[globalScope], dropped)
+	if globalsChanged {
+		limited.globals = globals
+	}
+	truncated = append(append(append(truncated, fieldsTruncated...), sessionTruncated...), globalsTruncated...)
+	if len(truncated) == 0 {
+		return event
+	}
+	limited.Fields = make(Fields, len(fields)+1)
+	for key, value := range fields {
+		limited.Fields[key] = value
+	}
+	limited.Fields[TruncatedKey] = truncated
+	return &limited
+}
+
+// droppedField is a field which format may drop, in the order of dropping
+type droppedField struct {
+	scope int
+	key   string
+	size  int
+}
+
+// scopes of dropped fields and their prefixes in TruncatedKey
+const (
+	eventScope = iota
+	sessionScope
+	globalScope
+)
+
+var scopePrefixes = [...]string{"fields.", "session.", "global."}
+
+// fieldOverhead is the estimated size of quotes and separators of a field, as `"key":"value",`
+const fieldOverhead = 6
+
+// format render the limited event, dropping the largest event fields, then
+// session fields and global fields, and cutting the message until the content
+// fits MaxEventBytes
+func (limits *Limits) format(event *Event, render func(*Event) ([]byte, error)) ([]byte, error) {
+	if !limits.enabled() {
+		return render(event)
+	}
+	limited := limits.truncate(event)
+	content, err := render(limited)
+	if err != nil || limits.MaxEventBytes <= 0 || len(content) <= limits.MaxEventBytes {
+		return content, err
+	}
+	if limited == event {
+		copied := *event
+		copied.Fields = make(Fields, len(event.Fields)+1)
+		for key, value := range event.Fields {
+			copied.Fields[key] = value
+		}
+		limited = &copied
+	}
+	truncated, _ := limited.Fields[TruncatedKey].([]string)
+	scopes := [...]map[string]interface{}{limited.Fields, limited.Session, limited.globalView()}
+	var candidates []droppedField
+	for scope, fields := range scopes {
+		start := len(candidates)
+		for key, value := range fields {
+			if scope == eventScope && key == TruncatedKey {
+				continue
+			}
+			// 被覆盖的字段不会输出
+			if scope > eventScope && limited.hasField(key) {
+				continue
+			}
+			if _, found := limited.Session[key]; found && scope == globalScope {
+				continue
+			}
+			candidates = append(candidates, droppedField{scope, key, len(key) + len(fmt.Sprint(value)) + fieldOverhead})
+		}
+		sorted := candidates[start:]
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].size != sorted[j].size {
+				return sorted[i].size > sorted[j].size
+			}
+			return sorted[i].key < sorted[j].key
+		})
+	}
+	// 依次丢弃事件字段, 会话字段和全局字段, 各自从最大的字段开始, 直到满足总大小限制
+	copiedSession, copiedGlobals := false, false
+	for len(content) > limits.MaxEventBytes && len(candidates) > 0 {
+		for excess := len(content) - limits.MaxEventBytes; excess > 0 && len(candidates) > 0; candidates = candidates[1:] {
+			field := candidates[0]
+			excess -= field.size
+			switch field.scope {
+			case eventScope:
+				delete(limited.Fields, field.key)
+			case sessionScope:
+				if !copiedSession {
+					limited.originSession = event.origin()
+					limited.Session = copyFields(limited.Session)
+					copiedSession = true
+				}
+				delete(limited.Session, field.key)
+			case globalScope:
+				if !copiedGlobals {
+					limited.globals = copyFields(limited.globalView())
+					copiedGlobals = true
+				}
+				delete(limited.globals, field.key)
+			}
+			truncated = append(truncated, scopePrefixes[field.scope]+field.key)
+		}
+		limited.Fields[TruncatedKey] = truncated
+		if content, err = render(limited); err != nil {
+			return content, err
+		}
+	}
+	for excess := len(content) - limits.MaxEventBytes; excess > 0 && limited.Message != ""; excess = len(content) - limits.MaxEventBytes {
+		if len(truncated) == 0 || truncated[0] != "message" {
+			truncated = append([]string{"message"}, truncated...)
+			limited.Fields[TruncatedKey] = truncated
+		}
+		limited.Message = cutString(limited.Message, len(limited.Message)-excess)
+		if content, err = render(limited); err != nil {
+			return content, err
+		}
+	}
+	return content, nil
+}
+
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		copied[key] = value
+	}
+	return copied
+}
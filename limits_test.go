@@ -0,0 +1,166 @@
+package slog
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLimitsTruncate(t *testing.T) {
+	defer func(fields map[string]interface{}, order []string) {
+		globalFields, globalOrder = fields, order
+	}(globalFields, globalOrder)
+	globalFields, globalOrder = make(map[string]interface{}), nil
+	limits := &Limits{MaxMessageBytes: 8, MaxValueBytes: 5, MaxFields: 4, MaxDepth: 1}
+	event := newEvent(1, Session{"request_id": "0123456789"})
+	event.Message = "日志消息很长"
+	event.Fields["body"] = "abcdefghij"
+	event.Fields["count"] = 12345678
+	event.Fields["deep"] = map[string]interface{}{"inner": map[string]interface{}{"x": 1}, "y": 2}
+	event.Fields["zzz"] = "dropped"
+	limited := limits.truncate(event)
+	if limited.Message != "日志" {
+		t.Errorf("unexpected message: %q", limited.Message)
+	}
+	if limited.Fields["body"] != "abcde" || limited.Fields["count"] != 12345678 || limited.Session["request_id"] != "01234" {
+		t.Errorf("unexpected fields: %v %v", limited.Fields, limited.Session)
+	}
+	if _, found := limited.Fields["zzz"]; found {
+		t.Error("field beyond MaxFields kept")
+	}
+	if deep := limited.Fields["deep"].(map[string]interface{}); deep["inner"] != depthMarker || deep["y"] != 2 {
+		t.Errorf("unexpected deep field: %v", deep)
+	}
+	expected := []string{"message", "fields.zzz", "fields.body", "fields.deep", "session.request_id"}
+	if truncated := limited.Fields[TruncatedKey]; !reflect.DeepEqual(truncated, expected) {
+		t.Errorf("unexpected truncated: %v", truncated)
+	}
+	if event.Fields["body"] != "abcdefghij" || event.Session["request_id"] != "0123456789" {
+		t.Error("original event changed")
+	}
+	if limited.sessionID() != sessionID(event.Session) {
+		t.Error("session identity not kept")
+	}
+	if (&Limits{MaxValueBytes: 100}).truncate(event) != event {
+		t.Error("event copied without truncation")
+	}
+}
+
+func TestLimitsMaxFields(t *testing.T) {
+	defer func(fields map[string]interface{}, order []string) {
+		globalFields, globalOrder = fields, order
+	}(globalFields, globalOrder)
+	globalFields, globalOrder = make(map[string]interface{}), nil
+	WithField("service", "demo")
+	WithField("env", "test")
+	session := NewSession().WithField("request_id", "1").WithField("user", "alice").WithField("env", "session")
+	defer session.End()
+	writer := new(bufferWriter)
+	handler := &JsonHandler{Writer: writer, Limits: Limits{MaxFields: 3}}
+	event := newEvent(1, session)
+	event.Level = "info"
+	event.Message = "message"
+	event.WithField("b", 1).WithField("a", 2).With(String("typed", "t"))
+	handler.Handle(event)
+	content := writer.String()
+	// 合并后按输出顺序保留前MaxFields个字段, 被覆盖的全局字段不计数
+	if !strings.Contains(content, `"service":"demo","request_id":"1","user":"alice","_truncated":["session.env","fields.b","fields.a","fields.typed"]`) {
+		t.Errorf("unexpected content: %s", content)
+	}
+	if !event.hasField("typed") || len(session) != 3 || len(globalFields) != 2 {
+		t.Error("original fields changed")
+	}
+}
+
+func TestJsonHandlerMaxEventBytes(t *testing.T) {
+	defer func(fields map[string]interface{}, order []string) {
+		globalFields, globalOrder = fields, order
+	}(globalFields, globalOrder)
+	globalFields, globalOrder = make(map[string]interface{}), nil
+	defer SetHandlers(SetHandlers(map[string][]Handler{}))
+	writer := new(bufferWriter)
+	handler := &JsonHandler{Writer: writer, Limits: Limits{MaxEventBytes: 400}}
+	event := newEvent(1, nil)
+	event.Level = "info"
+	event.Message = strings.Repeat("m", 100)
+	event.Fields["small"] = "ok"
+	event.Fields["large"] = strings.Repeat("x", 1000)
+	handler.Handle(event)
+	if writer.Len() > 400 {
+		t.Errorf("event too large: %d bytes", writer.Len())
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(writer.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := fields["large"]; found || fields["small"] != "ok" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if truncated := fields[TruncatedKey].([]interface{}); len(truncated) != 1 || truncated[0] != "fields.large" {
+		t.Errorf("unexpected truncated: %v", truncated)
+	}
+
+	writer.Reset()
+	handler.Limits.MaxEventBytes = 250
+	handler.Handle(event)
+	if writer.Len() > 250 {
+		t.Errorf("event too large: %d bytes", writer.Len())
+	}
+	if err := json.Unmarshal(writer.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if truncated := fields[TruncatedKey].([]interface{}); truncated[0] != "message" {
+		t.Errorf("unexpected truncated: %v", truncated)
+	}
+}
+
+func TestJsonHandlerMaxEventBytesScopes(t *testing.T) {
+	defer func(fields map[string]interface{}, order []string) {
+		globalFields, globalOrder = fields, order
+	}(globalFields, globalOrder)
+	globalFields, globalOrder = make(map[string]interface{}), nil
+	WithField("service", "demo")
+	WithField("build", strings.Repeat("g", 300))
+	writer := new(bufferWriter)
+	handler := &JsonHandler{Writer: writer, Limits: Limits{MaxEventBytes: 400}}
+	session := Session{"request_id": "1", "body": strings.Repeat("s", 300)}
+	event := newEvent(1, session)
+	event.Level = "info"
+	event.Message = "message"
+	event.Fields["large"] = strings.Repeat("x", 300)
+	handler.Handle(event)
+	if writer.Len() > 400 {
+		t.Errorf("event too large: %d bytes", writer.Len())
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(writer.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["service"] != "demo" || fields["message"] != "message" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	// 会话字段先于全局字段丢弃
+	expected := []interface{}{"fields.large", "session.body", "session.request_id", "global.build"}
+	if truncated := fields[TruncatedKey]; !reflect.DeepEqual(truncated, expected) {
+		t.Errorf("unexpected truncated: %v", truncated)
+	}
+	if len(session) != 2 || len(globalFields) != 2 {
+		t.Error("session or global fields changed")
+	}
+}
+
+func TestPlainTextFormatterLimits(t *testing.T) {
+	formatter := &PlainTextFormatter{
+		EventFormat: "%(message|s) %(.event_fields_space_seperated_text|s)",
+		SortFields:  true,
+		Limits:      Limits{MaxMessageBytes: 4, MaxValueBytes: 3},
+	}
+	event := newEvent(1, nil)
+	event.Message = "message"
+	event.Fields["body"] = "abcdef"
+	content, _ := formatter.FormatEvent(event)
+	if string(content) != `mess _truncated=[message fields.body] body="abc"` {
+		t.Errorf("unexpected content: %s", content)
+	}
+}
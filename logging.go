@@ -2,104 +2,144 @@ package slog
 
 import (
 	"fmt"
-	"runtime/debug"
 )
 
 var (
-	globalFields  = make(map[string]interface{})
+	globalFields = make(map[string]interface{})
+	// globalValuers is set once a Valuer is added to global fields, so that
+	// events without them skip resolving global fields
+	globalValuers bool
 	GlobalSession = Session(make(map[string]interface{}))
 )
 
 func WithField(key string, value interface{}) {
+	globalOrder = appendKey(globalOrder, key)
 	globalFields[key] = value
+	if _, ok := value.(Valuer); ok {
+		globalValuers = true
+	}
 }
 
 func WithFields(fields map[string]interface{}) {
+	for _, key := range sortedKeys(fields) {
+		globalOrder = appendKey(globalOrder, key)
+	}
 	for key, value := range fields {
 		globalFields[key] = value
+		if _, ok := value.(Valuer); ok {
+			globalValuers = true
+		}
+	}
+}
+
+// EventAt create a new event of GlobalSession with level, see Session.EventAt
+func EventAt(level string) *Event {
+	if level != "panic" && !Enabled(level) {
+		return disabledEvent
 	}
+	event := GlobalSession.EventSkip(2)
+	event.Level = level
+	return event
 }
 
 func Debug(args ...interface{}) {
-	GlobalSession.EventSkip(2).Log("debug", args...)
+	if Enabled("debug") {
+		GlobalSession.pooledEventSkip(2).Log("debug", args...)
+	}
 }
 
 func Debugf(format string, args ...interface{}) {
-	GlobalSession.EventSkip(2).Logf("debug", format, args...)
+	if Enabled("debug") {
+		GlobalSession.pooledEventSkip(2).Logf("debug", format, args...)
+	}
 }
 
 func Debugln(args ...interface{}) {
-	GlobalSession.EventSkip(2).Logln("debug", args...)
+	if Enabled("debug") {
+		GlobalSession.pooledEventSkip(2).Logln("debug", args...)
+	}
 }
 
 func Info(args ...interface{}) {
-	GlobalSession.EventSkip(2).Log("info", args...)
+	if Enabled("info") {
+		GlobalSession.pooledEventSkip(2).Log("info", args...)
+	}
 }
 
 func Infof(format string, args ...interface{}) {
-	GlobalSession.EventSkip(2).Logf("info", format, args...)
+	if Enabled("info") {
+		GlobalSession.pooledEventSkip(2).Logf("info", format, args...)
+	}
 }
 
 func Infoln(args ...interface{}) {
-	GlobalSession.EventSkip(2).Logln("info", args...)
+	if Enabled("info") {
+		GlobalSession.pooledEventSkip(2).Logln("info", args...)
+	}
 }
 
 func Warn(args ...interface{}) {
-	GlobalSession.EventSkip(2).Log("warn", args...)
+	if Enabled("warn") {
+		GlobalSession.pooledEventSkip(2).Log("warn", args...)
+	}
 }
 
 func Warnf(format string, args ...interface{}) {
-	GlobalSession.EventSkip(2).Logf("warn", format, args...)
+	if Enabled("warn") {
+		GlobalSession.pooledEventSkip(2).Logf("warn", format, args...)
+	}
 }
 
 func Warnln(args ...interface{}) {
-	GlobalSession.EventSkip(2).Logln("warn", args...)
+	if Enabled("warn") {
+		GlobalSession.pooledEventSkip(2).Logln("warn", args...)
+	}
 }
 
 func Error(args ...interface{}) {
-	GlobalSession.EventSkip(2).Log("error", args...)
+	if Enabled("error") {
+		GlobalSession.pooledEventSkip(2).Log("error", args...)
+	}
 }
 
 func Errorf(format string, args ...interface{}) {
-	GlobalSession.EventSkip(2).Logf("error", format, args...)
+	if Enabled("error") {
+		GlobalSession.pooledEventSkip(2).Logf("error", format, args...)
+	}
 }
 
 func Errorln(args ...interface{}) {
-	GlobalSession.EventSkip(2).Logln("error", args...)
+	if Enabled("error") {
+		GlobalSession.pooledEventSkip(2).Logln("error", args...)
+	}
 }
 
 func Fatal(args ...interface{}) {
-	GlobalSession.EventSkip(2).Log("fatal", args...)
+	if Enabled("fatal") {
+		GlobalSession.pooledEventSkip(2).Log("fatal", args...)
+	}
 }
 
 func Fatalf(format string, args ...interface{}) {
-	GlobalSession.EventSkip(2).Logf("fatal", format, args...)
+	if Enabled("fatal") {
+		GlobalSession.pooledEventSkip(2).Logf("fatal", format, args...)
+	}
 }
 
 func Fatalln(args ...interface{}) {
-	GlobalSession.EventSkip(2).Logln("fatal", args...)
+	if Enabled("fatal") {
+		GlobalSession.pooledEventSkip(2).Logln("fatal", args...)
+	}
 }
 
 func Panic(args ...interface{}) {
-	event := GlobalSession.EventSkip(2)
-	event.Level = "panic"
-	event.Message = fmt.Sprint(args...)
-	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	GlobalSession.EventSkip(2).throw(fmt.Sprint(args...))
 }
 
 func Panicf(format string, args ...interface{}) {
-	event := GlobalSession.EventSkip(2)
-	event.Level = "panic"
-	event.Message = fmt.Sprintf(format, args...)
-	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	GlobalSession.EventSkip(2).throw(fmt.Sprintf(format, args...))
 }
 
 func Panicln(args ...interface{}) {
-	event := GlobalSession.EventSkip(2)
-	event.Level = "panic"
-	event.Message = fmt.Sprintln(args...)
-	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	GlobalSession.EventSkip(2).throw(fmt.Sprintln(args...))
 }
@@ -0,0 +1,438 @@
+package slog
+
+import (
+	"bufio"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of latency histograms
+var DefaultLatencyBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// metricSeries is updated with atomic operations, float values are stored as
+// their bits
+type metricSeries struct {
+	labelValues  []string
+	value        uint64
+	bucketCounts []uint64
+	sum          uint64
+	count        uint64
+}
+
+func addFloat(bits *uint64, delta float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		if atomic.CompareAndSwapUint64(bits, old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func loadFloat(bits *uint64) float64 {
+	return math.Float64frombits(atomic.LoadUint64(bits))
+}
+
+func (series *metricSeries) add(delta float64) {
+	addFloat(&series.value, delta)
+}
+
+func (series *metricSeries) observe(value float64, buckets []float64) {
+	for i, bound := range buckets {
+		if value <= bound {
+			atomic.AddUint64(&series.bucketCounts[i], 1)
+		}
+	}
+	addFloat(&series.sum, value)
+	atomic.AddUint64(&series.count, 1)
+}
+
+// overflowLabelValue replace label values of series beyond the series limit
+const overflowLabelValue = "other"
+
+// metricFamily is a counter or histogram with fixed label names, series beyond
+// maxSeries are merged into the series whose label values are all "other".
+// Series are created under the lock and looked up without it in resolved,
+// a copy of series replaced when a series is created.
+type metricFamily struct {
+	sync.Mutex
+	name       string
+	help       string
+	histogram  bool
+	labelNames []string
+	buckets    []float64
+	maxSeries  int
+	series     map[string]*metricSeries
+	resolved   atomic.Value
+}
+
+func newCounterFamily(name, help string, labelNames ...string) *metricFamily {
+	return &metricFamily{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		series:     make(map[string]*metricSeries),
+	}
+}
+
+func newHistogramFamily(name, help string, buckets []float64, labelNames ...string) *metricFamily {
+	family := newCounterFamily(name, help, labelNames...)
+	family.histogram = true
+	family.buckets = buckets
+	return family
+}
+
+// get return the series of labelValues, the family must be locked
+func (family *metricFamily) get(labelValues []string) *metricSeries {
+	var key string
+	if len(labelValues) == 1 {
+		key = labelValues[0]
+	} else {
+		key = strings.Join(labelValues, "\xff")
+	}
+	series := family.series[key]
+	if series == nil && family.maxSeries > 0 && len(family.series) >= family.maxSeries {
+		overflow := make([]string, len(labelValues))
+		for i := range overflow {
+			overflow[i] = overflowLabelValue
+		}
+		labelValues = overflow
+		key = strings.Join(labelValues, "\xff")
+		series = family.series[key]
+	}
+	if series == nil {
+		series = &metricSeries{labelValues: append([]string{}, labelValues...)}
+		if family.histogram {
+			series.bucketCounts = make([]uint64, len(family.buckets))
+		}
+		family.series[key] = series
+	}
+	return series
+}
+
+// lookup return the series of labelValues, which is created if not found
+func (family *metricFamily) lookup(labelValues []string) *metricSeries {
+	resolved, _ := family.resolved.Load().(map[string]*metricSeries)
+	if len(labelValues) == 1 {
+		if series := resolved[labelValues[0]]; series != nil {
+			return series
+		}
+	} else if series := resolved[strings.Join(labelValues, "\xff")]; series != nil {
+		return series
+	}
+	family.Lock()
+	defer family.Unlock()
+	series := family.get(labelValues)
+	copied := make(map[string]*metricSeries, len(family.series)+1)
+	for key, value := range family.series {
+		copied[key] = value
+	}
+	// 超出限制的标签值也指向合并的序列, 避免重复加锁
+	copied[strings.Join(labelValues, "\xff")] = series
+	family.resolved.Store(copied)
+	return series
+}
+
+func (family *metricFamily) add(delta float64, labelValues ...string) {
+	family.lookup(labelValues).add(delta)
+}
+
+func (family *metricFamily) observe(value float64, labelValues ...string) {
+	family.lookup(labelValues).observe(value, family.buckets)
+}
+
+func (family *metricFamily) sortedSeries() []*metricSeries {
+	series := make([]*metricSeries, 0, len(family.series))
+	for _, s := range family.series {
+		series = append(series, s)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return strings.Join(series[i].labelValues, "\xff") < strings.Join(series[j].labelValues, "\xff")
+	})
+	return series
+}
+
+// write the family in Prometheus text exposition format
+func (family *metricFamily) write(writer io.Writer) {
+	family.Lock()
+	defer family.Unlock()
+	kind := "counter"
+	if family.histogram {
+		kind = "histogram"
+	}
+	fmt.Fprintf(writer, "# HELP %s %s\n", family.name, family.help)
+	fmt.Fprintf(writer, "# TYPE %s %s\n", family.name, kind)
+	for _, series := range family.sortedSeries() {
+		labels := formatLabels(family.labelNames, series.labelValues)
+		if !family.histogram {
+			fmt.Fprintf(writer, "%s%s %s\n", family.name, wrapLabels(labels), formatMetricValue(loadFloat(&series.value)))
+			continue
+		}
+		for i, bound := range family.buckets {
+			fmt.Fprintf(writer, "%s_bucket%s %d\n", family.name,
+				wrapLabels(joinLabels(labels, "le=\""+formatMetricValue(bound)+"\"")), atomic.LoadUint64(&series.bucketCounts[i]))
+		}
+		count := atomic.LoadUint64(&series.count)
+		fmt.Fprintf(writer, "%s_bucket%s %d\n", family.name, wrapLabels(joinLabels(labels, "le=\"+Inf\"")), count)
+		fmt.Fprintf(writer, "%s_sum%s %s\n", family.name, wrapLabels(labels), formatMetricValue(loadFloat(&series.sum)))
+		fmt.Fprintf(writer, "%s_count%s %d\n", family.name, wrapLabels(labels), count)
+	}
+}
+
+// snapshot return values of the family for expvar
+func (family *metricFamily) snapshot() map[string]interface{} {
+	family.Lock()
+	defer family.Unlock()
+	values := make(map[string]interface{}, len(family.series))
+	for _, series := range family.sortedSeries() {
+		key := formatLabels(family.labelNames, series.labelValues)
+		if !family.histogram {
+			values[key] = loadFloat(&series.value)
+			continue
+		}
+		buckets := make(map[string]uint64, len(family.buckets))
+		for i, bound := range family.buckets {
+			buckets[formatMetricValue(bound)] = atomic.LoadUint64(&series.bucketCounts[i])
+		}
+		values[key] = map[string]interface{}{
+			"buckets": buckets,
+			"sum":     loadFloat(&series.sum),
+			"count":   atomic.LoadUint64(&series.count),
+		}
+	}
+	return values
+}
+
+var labelValueEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func formatLabels(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=\"" + labelValueEscaper.Replace(values[i]) + "\""
+	}
+	return strings.Join(pairs, ",")
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func wrapLabels(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	eventsTotal = newCounterFamily("slog_events_total",
+		"Number of events written by level.", "level")
+	handlerEventsTotal = newCounterFamily("slog_handler_events_total",
+		"Number of events passed to handlers.", "handler")
+	handleDuration = newHistogramFamily("slog_handle_duration_seconds",
+		"Latency of Handle.", DefaultLatencyBuckets, "handler")
+	writerBytesTotal = newCounterFamily("slog_writer_bytes_total",
+		"Number of bytes written by writers.", "writer")
+	writeDuration = newHistogramFamily("slog_write_duration_seconds",
+		"Latency of Write.", DefaultLatencyBuckets, "writer")
+	writeErrorsTotal = newCounterFamily("slog_write_errors_total",
+		"Number of failed writes.", "writer")
+	droppedEventsTotal = newCounterFamily("slog_dropped_events_total",
+		"Number of events dropped by handlers.", "handler")
+	suppressedEventsTotal = newCounterFamily("slog_suppressed_events_total",
+		"Number of events suppressed by handlers and reported in summaries.", "handler")
+	errorsTotal = newCounterFamily("slog_errors_total",
+		"Number of internal errors by source.", "source")
+	forgottenOrdersTotal = newCounterFamily("slog_forgotten_session_orders_total",
+		"Number of session key orders forgotten as too many sessions are alive.")
+	pipelineMetrics = []*metricFamily{
+		eventsTotal,
+		handlerEventsTotal,
+		handleDuration,
+		writerBytesTotal,
+		writeDuration,
+		writeErrorsTotal,
+		droppedEventsTotal,
+		suppressedEventsTotal,
+		errorsTotal,
+		forgottenOrdersTotal,
+	}
+)
+
+// PipelineMetrics serve metrics of the logging pipeline in Prometheus text format
+var PipelineMetrics http.Handler = http.HandlerFunc(servePipelineMetrics)
+
+// metricsDisabled is set by DisablePipelineMetrics
+var metricsDisabled int32
+
+// DisablePipelineMetrics stop or restart recording the metrics of the logging
+// pipeline, which cost a clock reading per handler and writer of each event
+func DisablePipelineMetrics(disabled bool) {
+	var value int32
+	if disabled {
+		value = 1
+	}
+	atomic.StoreInt32(&metricsDisabled, value)
+}
+
+func pipelineMetricsEnabled() bool {
+	return atomic.LoadInt32(&metricsDisabled) == 0
+}
+
+var publishExpvarOnce sync.Once
+
+// PublishExpvar publish the metrics of the logging pipeline to expvar as "slog",
+// it does nothing if the name is published already
+func PublishExpvar() {
+	publishExpvarOnce.Do(func() {
+		if expvar.Get("slog") != nil {
+			return
+		}
+		expvar.Publish("slog", expvar.Func(func() interface{} {
+			values := make(map[string]interface{}, len(pipelineMetrics))
+			for _, family := range pipelineMetrics {
+				values[family.name] = family.snapshot()
+			}
+			return values
+		}))
+	})
+}
+
+// WritePipelineMetrics write metrics of the logging pipeline in Prometheus text format
+func WritePipelineMetrics(writer io.Writer) error {
+	buffered := bufio.NewWriter(writer)
+	for _, family := range pipelineMetrics {
+		family.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+func servePipelineMetrics(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WritePipelineMetrics(writer)
+}
+
+// typeName return the type name of value without package and pointer
+func typeName(value interface{}) string {
+	t := reflect.TypeOf(value)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return "nil"
+	}
+	return t.Name()
+}
+
+// typeSeries is the series of a handler or writer type
+type typeSeries struct {
+	total    *metricSeries
+	duration *metricSeries
+	errors   *metricSeries
+}
+
+// typeSeriesCache resolve the series of handler and writer types once, the
+// map is copied when a type is added
+type typeSeriesCache struct {
+	sync.Mutex
+	resolved atomic.Value
+	resolve  func(name string) *typeSeries
+}
+
+func (cache *typeSeriesCache) get(value interface{}) *typeSeries {
+	t := reflect.TypeOf(value)
+	resolved, _ := cache.resolved.Load().(map[reflect.Type]*typeSeries)
+	if series := resolved[t]; series != nil {
+		return series
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	resolved, _ = cache.resolved.Load().(map[reflect.Type]*typeSeries)
+	if series := resolved[t]; series != nil {
+		return series
+	}
+	copied := make(map[reflect.Type]*typeSeries, len(resolved)+1)
+	for key, value := range resolved {
+		copied[key] = value
+	}
+	series := cache.resolve(typeName(value))
+	copied[t] = series
+	cache.resolved.Store(copied)
+	return series
+}
+
+var (
+	handlerSeries = &typeSeriesCache{resolve: func(name string) *typeSeries {
+		return &typeSeries{
+			total:    handlerEventsTotal.lookup([]string{name}),
+			duration: handleDuration.lookup([]string{name}),
+		}
+	}}
+	writerSeries = &typeSeriesCache{resolve: func(name string) *typeSeries {
+		return &typeSeries{
+			total:    writerBytesTotal.lookup([]string{name}),
+			duration: writeDuration.lookup([]string{name}),
+			errors:   writeErrorsTotal.lookup([]string{name}),
+		}
+	}}
+)
+
+// countEvent record an event written at level
+func countEvent(level string) {
+	if pipelineMetricsEnabled() {
+		eventsTotal.add(1, level)
+	}
+}
+
+// handleEvent pass event to handler and record its metrics
+func handleEvent(handler Handler, event *Event) {
+	if !pipelineMetricsEnabled() {
+		handler.Handle(event)
+		return
+	}
+	series := handlerSeries.get(handler)
+	start := time.Now()
+	handler.Handle(event)
+	series.duration.observe(time.Since(start).Seconds(), handleDuration.buckets)
+	series.total.add(1)
+}
+
+// writeContent write content with writer and record its metrics
+func writeContent(writer Writer, content []byte) error {
+	if !isTransientWriter(writer) {
+		// 缓冲会被复用, 可能保留内容的写入器写入副本
+		content = append([]byte(nil), content...)
+	}
+	if !pipelineMetricsEnabled() {
+		return writer.Write(content)
+	}
+	series := writerSeries.get(writer)
+	start := time.Now()
+	err := writer.Write(content)
+	series.duration.observe(time.Since(start).Seconds(), writeDuration.buckets)
+	if err != nil {
+		series.errors.add(1)
+	} else {
+		series.total.add(float64(len(content)))
+	}
+	return err
+}
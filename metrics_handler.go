@@ -0,0 +1,237 @@
+package slog
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// MetricRule describe a metric derived from events. Events matching Filter, or
+// all events if it is empty, are counted or observed. Labels and Field are
+// references as in Filter, such as `level`, `caller.package` or
+// `fields.route`, label names are the references without `fields.`,
+// `session.` and `global.` prefixes and with dots replaced by underscores.
+// Counters add 1 per event or the value of Field if it is set, histograms
+// observe the value of Field, events without numeric Field values are skipped.
+// Series beyond MaxSeries are merged into the series labelled "other".
+type MetricRule struct {
+	Name      string
+	Help      string
+	Type      string
+	Filter    string
+	Labels    []string
+	Field     string
+	Buckets   []float64
+	MaxSeries int
+}
+
+type compiledMetricRule struct {
+	family *metricFamily
+	filter *Filter
+	labels []filterNode
+	field  filterNode
+}
+
+// MetricsHandler generate Prometheus counters and histograms from events by
+// Rules, it is also an http.Handler serving them in Prometheus text format.
+// Rules can also be declared by Metrics of Config and served by ConfigMetrics.
+type MetricsHandler struct {
+	sync.Mutex
+	Rules []MetricRule
+	rules []*compiledMetricRule
+	err   error
+	// compiled hold rules once compiled, so that events are handled without the lock
+	compiled atomic.Value
+}
+
+// configMetrics is the handler of Metrics of the loaded Config, guarded by handlersLock
+var configMetrics *MetricsHandler
+
+// ConfigMetrics serve the metrics of the rules in Metrics of the loaded Config
+// in Prometheus text format
+var ConfigMetrics http.Handler = http.HandlerFunc(serveConfigMetrics)
+
+func serveConfigMetrics(writer http.ResponseWriter, request *http.Request) {
+	handlersLock.RLock()
+	handler := configMetrics
+	handlersLock.RUnlock()
+	if handler == nil {
+		handler = new(MetricsHandler)
+	}
+	handler.ServeHTTP(writer, request)
+}
+
+// Initialize compile the rules
+func (handler *MetricsHandler) Initialize() error {
+	handler.Lock()
+	defer handler.Unlock()
+	return handler.compile()
+}
+
+func (handler *MetricsHandler) compile() error {
+	if handler.rules != nil || handler.err != nil {
+		return handler.err
+	}
+	rules := make([]*compiledMetricRule, 0, len(handler.Rules))
+	names := make(map[string]bool)
+	for _, rule := range handler.Rules {
+		compiled, err := compileMetricRule(rule)
+		if err == nil && names[rule.Name] {
+			err = fmt.Errorf("duplicated metric %q", rule.Name)
+		}
+		if err != nil {
+			handler.err = err
+			return err
+		}
+		names[rule.Name] = true
+		rules = append(rules, compiled)
+	}
+	handler.rules = rules
+	handler.compiled.Store(rules)
+	return nil
+}
+
+// compiledRules return the compiled rules, compiling them under the lock at
+// the first call if the handler is not initialized
+func (handler *MetricsHandler) compiledRules() ([]*compiledMetricRule, error) {
+	if rules, ok := handler.compiled.Load().([]*compiledMetricRule); ok {
+		return rules, nil
+	}
+	handler.Lock()
+	defer handler.Unlock()
+	err := handler.compile()
+	return handler.rules, err
+}
+
+func compileMetricRule(rule MetricRule) (*compiledMetricRule, error) {
+	if !metricNamePattern.MatchString(rule.Name) {
+		return nil, fmt.Errorf("invalid metric name %q", rule.Name)
+	}
+	compiled := &compiledMetricRule{labels: make([]filterNode, len(rule.Labels))}
+	var err error
+	if rule.Filter != "" {
+		if compiled.filter, err = CompileFilter(rule.Filter); err != nil {
+			return nil, fmt.Errorf("metric %q: %s", rule.Name, err.Error())
+		}
+	}
+	labelNames := make([]string, len(rule.Labels))
+	for i, label := range rule.Labels {
+		if compiled.labels[i], err = parseFilterReference(label); err != nil {
+			return nil, fmt.Errorf("metric %q: %s", rule.Name, err.Error())
+		}
+		labelNames[i] = metricLabelName(label)
+		if !metricNamePattern.MatchString(labelNames[i]) || strings.Contains(labelNames[i], ":") {
+			return nil, fmt.Errorf("metric %q: invalid label name %q", rule.Name, labelNames[i])
+		}
+	}
+	if rule.Field != "" {
+		if compiled.field, err = parseFilterReference(rule.Field); err != nil {
+			return nil, fmt.Errorf("metric %q: %s", rule.Name, err.Error())
+		}
+	}
+	help := rule.Help
+	if help == "" {
+		help = "Derived from log events."
+	}
+	switch rule.Type {
+	case "", "counter":
+		compiled.family = newCounterFamily(rule.Name, help, labelNames...)
+	case "histogram":
+		if compiled.field == nil {
+			return nil, fmt.Errorf("metric %q: histogram without field", rule.Name)
+		}
+		buckets := rule.Buckets
+		if len(buckets) == 0 {
+			buckets = []float64{1, 5, 10, 50, 100, 500, 1000, 5000}
+		}
+		compiled.family = newHistogramFamily(rule.Name, help, buckets, labelNames...)
+	default:
+		return nil, fmt.Errorf("metric %q: unknown type %q", rule.Name, rule.Type)
+	}
+	compiled.family.maxSeries = rule.MaxSeries
+	if compiled.family.maxSeries <= 0 {
+		compiled.family.maxSeries = 1000
+	}
+	return compiled, nil
+}
+
+// metricLabelName convert reference to label name
+func metricLabelName(reference string) string {
+	for _, prefix := range []string{"fields.", "session.", "global."} {
+		if strings.HasPrefix(reference, prefix) {
+			reference = reference[len(prefix):]
+			break
+		}
+	}
+	return strings.Replace(reference, ".", "_", -1)
+}
+
+func (handler *MetricsHandler) Handle(event *Event) {
+	rules, err := handler.compiledRules()
+	if err != nil {
+		reportError("metrics", event, &handleError{"compile metric rules fail", err})
+		return
+	}
+	for _, rule := range rules {
+		rule.handle(event)
+	}
+}
+
+// Transient return true as events are not kept
+func (handler *MetricsHandler) Transient() bool {
+	return true
+}
+
+func (rule *compiledMetricRule) handle(event *Event) {
+	if rule.filter != nil && !rule.filter.Match(event) {
+		return
+	}
+	value := 1.0
+	if rule.field != nil {
+		var ok bool
+		if value, ok = metricValue(rule.field.eval(event)); !ok {
+			return
+		}
+	}
+	labelValues := make([]string, len(rule.labels))
+	for i, label := range rule.labels {
+		if labelValue := label.eval(event); labelValue != nil {
+			labelValues[i] = fmt.Sprint(labelValue)
+		}
+	}
+	if rule.family.histogram {
+		rule.family.observe(value, labelValues...)
+	} else {
+		rule.family.add(value, labelValues...)
+	}
+}
+
+// metricValue convert numbers, numeric strings and durations in seconds to float64
+func metricValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v.Seconds(), true
+	case string:
+		number, err := strconv.ParseFloat(v, 64)
+		return number, err == nil
+	}
+	return toFloat(value)
+}
+
+func (handler *MetricsHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	rules, _ := handler.compiledRules()
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	buffered := bufio.NewWriter(writer)
+	for _, rule := range rules {
+		rule.family.write(buffered)
+	}
+	buffered.Flush()
+}
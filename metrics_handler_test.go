@@ -0,0 +1,120 @@
+package slog
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandlerCompileFail(t *testing.T) {
+	for _, rule := range []MetricRule{
+		{Name: "bad-name"},
+		{Name: "events_total", Filter: "level >="},
+		{Name: "events_total", Labels: []string{"unknown"}},
+		{Name: "duration", Type: "histogram"},
+		{Name: "events_total", Type: "gauge"},
+	} {
+		handler := &MetricsHandler{Rules: []MetricRule{rule}}
+		if err := handler.Initialize(); err == nil {
+			t.Error("unexpected success:", rule)
+		}
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	handler := &MetricsHandler{
+		Rules: []MetricRule{
+			{
+				Name:   "app_events_total",
+				Labels: []string{"level", "caller.package"},
+			},
+			{
+				Name:    "app_request_duration_ms",
+				Type:    "histogram",
+				Filter:  "fields.route != \"\"",
+				Labels:  []string{"fields.route"},
+				Field:   "fields.duration_ms",
+				Buckets: []float64{10, 100},
+			},
+			{
+				Name:      "app_tenant_events_total",
+				Labels:    []string{"session.tenant"},
+				MaxSeries: 1,
+			},
+		},
+	}
+	if err := handler.Initialize(); err != nil {
+		t.Error("initialize fail:", err.Error())
+		return
+	}
+	for i, duration := range []interface{}{5, 50.0, "500", "x"} {
+		event := newEvent(1, NewSession().WithField("tenant", []string{"a", "b"}[i%2]))
+		event.Level = infoLevel
+		event.WithField("route", "/users").WithField("duration_ms", duration)
+		handler.Handle(event)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"app_events_total{level=\"info\",caller_package=\"github.com/yangchenxing/go-slog\"} 4\n",
+		"app_request_duration_ms_bucket{route=\"/users\",le=\"10\"} 1\n",
+		"app_request_duration_ms_bucket{route=\"/users\",le=\"100\"} 2\n",
+		"app_request_duration_ms_bucket{route=\"/users\",le=\"+Inf\"} 3\n",
+		"app_request_duration_ms_sum{route=\"/users\"} 555\n",
+		"app_tenant_events_total{tenant=\"a\"} 2\n",
+		"app_tenant_events_total{tenant=\"other\"} 2\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("miss %q in metrics:\n%s", expected, body)
+		}
+	}
+}
+
+func TestLoadConfigMetrics(t *testing.T) {
+	defer SetHandlers(SetHandlers(map[string][]Handler{}))
+	defer func(metrics *MetricsHandler) {
+		configMetrics = metrics
+	}(configMetrics)
+	rules := []MetricRule{{Name: "app_config_events_total", Labels: []string{"level"}}}
+	config := Config{
+		Handlers: []HandlerConfig{{Levels: []string{"info", "warn"}, Handler: new(receiveHandler)}},
+		Metrics:  rules,
+	}
+	if err := LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	Info("counted")
+	Warn("counted")
+	Debug("not enabled")
+	// 规则未变时保留已有的序列
+	if err := LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	Info("counted")
+	config.Metrics = []MetricRule{{Name: "bad-name"}}
+	if err := LoadConfig(config); err == nil {
+		t.Error("invalid metric rule loaded")
+	}
+	recorder := httptest.NewRecorder()
+	ConfigMetrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"app_config_events_total{level=\"info\"} 2\n",
+		"app_config_events_total{level=\"warn\"} 1\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("miss %q in metrics:\n%s", expected, body)
+		}
+	}
+	if strings.Contains(body, "debug") {
+		t.Errorf("debug events counted:\n%s", body)
+	}
+	config.Metrics, config.MetricsLevels = rules, []string{"debug"}
+	if err := LoadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	if !Enabled("debug") || len(handlers["info"]) != 1 {
+		t.Errorf("metrics not registered for MetricsLevels: %v", handlers)
+	}
+}
@@ -0,0 +1,83 @@
+package slog
+
+import (
+	"bytes"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMetricFamilyWrite(t *testing.T) {
+	counter := newCounterFamily("test_total", "Test counter.", "level")
+	counter.add(1, "info")
+	counter.add(2, "info")
+	counter.add(1, "de\"bug")
+	histogram := newHistogramFamily("test_seconds", "Test histogram.", []float64{0.1, 1}, "handler")
+	histogram.observe(0.05, "json")
+	histogram.observe(0.5, "json")
+	var buffer bytes.Buffer
+	counter.write(&buffer)
+	histogram.write(&buffer)
+	expected := strings.Join([]string{
+		"# HELP test_total Test counter.",
+		"# TYPE test_total counter",
+		"test_total{level=\"de\\\"bug\"} 1",
+		"test_total{level=\"info\"} 3",
+		"# HELP test_seconds Test histogram.",
+		"# TYPE test_seconds histogram",
+		"test_seconds_bucket{handler=\"json\",le=\"0.1\"} 1",
+		"test_seconds_bucket{handler=\"json\",le=\"1\"} 2",
+		"test_seconds_bucket{handler=\"json\",le=\"+Inf\"} 2",
+		"test_seconds_sum{handler=\"json\"} 0.55",
+		"test_seconds_count{handler=\"json\"} 2",
+	}, "\n") + "\n"
+	if buffer.String() != expected {
+		t.Errorf("unexpected metrics: actual=%q, expected=%q\n", buffer.String(), expected)
+	}
+}
+
+func TestPipelineMetrics(t *testing.T) {
+	handlers = map[string][]Handler{
+		infoLevel: []Handler{&JsonHandler{Writer: new(bufferWriter)}},
+	}
+	defer func() { handlers = nil }()
+	newEvent(1, nil).Info("test")
+	recorder := httptest.NewRecorder()
+	PipelineMetrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		"slog_events_total{level=\"info\"}",
+		"slog_handler_events_total{handler=\"JsonHandler\"}",
+		"slog_handle_duration_seconds_count{handler=\"JsonHandler\"}",
+		"slog_writer_bytes_total{writer=\"bufferWriter\"}",
+		"slog_write_duration_seconds_count{writer=\"bufferWriter\"}",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("miss %s in metrics:\n%s", expected, body)
+		}
+	}
+	PublishExpvar()
+	PublishExpvar()
+	if value := expvar.Get("slog"); value == nil || !strings.Contains(value.String(), "slog_events_total") {
+		t.Error("unexpected expvar:", value)
+	}
+}
+
+func TestDisablePipelineMetrics(t *testing.T) {
+	handler := &JsonHandler{Writer: new(bufferWriter)}
+	defer SetHandlers(SetHandlers(map[string][]Handler{infoLevel: {handler}}))
+	series := handlerSeries.get(handler)
+	count := atomic.LoadUint64(&series.duration.count)
+	DisablePipelineMetrics(true)
+	newEvent(1, nil).Info("not counted")
+	DisablePipelineMetrics(false)
+	if atomic.LoadUint64(&series.duration.count) != count {
+		t.Error("metrics recorded while disabled")
+	}
+	newEvent(1, nil).Info("counted")
+	if atomic.LoadUint64(&series.duration.count) != count+1 {
+		t.Error("metrics not recorded")
+	}
+}
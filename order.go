@@ -0,0 +1,305 @@
+package slog
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// maxOrderedSessions is set by SetMaxOrderedSessions
+var maxOrderedSessions int64 = 4096
+
+// SetMaxOrderedSessions set the number of sessions whose key order is kept,
+// 4096 by default. The key order of a session is not stored in the Session map
+// but in a registry holding the session until it ends, so when more sessions
+// are alive, the orders of those neither changed nor read for the longest time
+// are forgotten, counted by slog_forgotten_session_orders_total of
+// PipelineMetrics, and their keys are written in alphabetical order. Sessions
+// should be ended by End to release their orders.
+func SetMaxOrderedSessions(max int) {
+	if max < orderShards {
+		max = orderShards
+	}
+	atomic.StoreInt64(&maxOrderedSessions, int64(max))
+}
+
+// orderShards is the number of shards of the session order registry, so that
+// sessions adding fields concurrently rarely wait for each other
+const orderShards = 16
+
+// sessionOrderEntry keep its session, so that the id of the entry is not
+// reused by new sessions while the order is recorded
+type sessionOrderEntry struct {
+	session Session
+	order   []string
+}
+
+// sessionOrderShard keep the orders of sessions in two generations, the
+// previous one is dropped when the current one is full, approximately LRU
+type sessionOrderShard struct {
+	sync.RWMutex
+	current  map[uintptr]*sessionOrderEntry
+	previous map[uintptr]*sessionOrderEntry
+}
+
+var (
+	sessionOrderShards [orderShards]sessionOrderShard
+	globalOrder        []string
+	keysPool           = sync.Pool{New: func() interface{} { return new([]string) }}
+)
+
+func init() {
+	for i := range sessionOrderShards {
+		sessionOrderShards[i].current = make(map[uintptr]*sessionOrderEntry)
+		sessionOrderShards[i].previous = make(map[uintptr]*sessionOrderEntry)
+	}
+	onSessionEnd(forgetSessionOrder)
+}
+
+func orderShard(id uintptr) *sessionOrderShard {
+	// 地址低位对齐, 乘法散列后取高位
+	return &sessionOrderShards[(uint64(id)*0x9E3779B97F4A7C15)>>60]
+}
+
+// appendKey append key to order if it is not in it
+func appendKey(order []string, key string) []string {
+	if containsKey(order, key) {
+		return order
+	}
+	return append(order, key)
+}
+
+func containsKey(order []string, key string) bool {
+	for _, k := range order {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys return the keys of fields in alphabetical order, it is used to
+// record maps such as Fields literals whose keys have no order
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// recordSessionKeys append keys to the recorded order of session
+func recordSessionKeys(session Session, keys ...string) {
+	id := sessionID(session)
+	if id == 0 {
+		return
+	}
+	shard := orderShard(id)
+	shard.RLock()
+	entry := shard.current[id]
+	recorded := entry != nil && containsKeys(entry.order, keys)
+	shard.RUnlock()
+	if recorded {
+		return
+	}
+	shard.Lock()
+	defer shard.Unlock()
+	entry = shard.promote(id)
+	if entry == nil {
+		entry = &sessionOrderEntry{session: session}
+		shard.add(id, entry)
+	}
+	for _, key := range keys {
+		entry.order = appendKey(entry.order, key)
+	}
+}
+
+func containsKeys(order []string, keys []string) bool {
+	for _, key := range keys {
+		if !containsKey(order, key) {
+			return false
+		}
+	}
+	return true
+}
+
+// promote return the entry of id, which is moved to the current generation
+// if it is in the previous one, the shard must be locked
+func (shard *sessionOrderShard) promote(id uintptr) *sessionOrderEntry {
+	if entry := shard.current[id]; entry != nil {
+		return entry
+	}
+	entry := shard.previous[id]
+	if entry != nil {
+		delete(shard.previous, id)
+		shard.add(id, entry)
+	}
+	return entry
+}
+
+// add put entry in the current generation, the shard must be locked
+func (shard *sessionOrderShard) add(id uintptr, entry *sessionOrderEntry) {
+	if len(shard.current) >= int(atomic.LoadInt64(&maxOrderedSessions))/orderShards {
+		if forgotten := len(shard.previous); forgotten > 0 {
+			forgottenOrdersTotal.add(float64(forgotten))
+		}
+		shard.previous = shard.current
+		shard.current = make(map[uintptr]*sessionOrderEntry)
+	}
+	shard.current[id] = entry
+}
+
+// sessionOrder return the recorded key order of session id, which must not
+// be changed, the order is kept as recently used
+func sessionOrder(id uintptr) []string {
+	if id == 0 {
+		return nil
+	}
+	shard := orderShard(id)
+	shard.RLock()
+	entry := shard.current[id]
+	_, previous := shard.previous[id]
+	var order []string
+	if entry != nil {
+		order = entry.order
+	}
+	shard.RUnlock()
+	if !previous {
+		return order
+	}
+	shard.Lock()
+	defer shard.Unlock()
+	if entry = shard.promote(id); entry != nil {
+		return entry.order
+	}
+	return nil
+}
+
+func forgetSessionOrder(session Session) {
+	id := sessionID(session)
+	shard := orderShard(id)
+	shard.Lock()
+	defer shard.Unlock()
+	delete(shard.current, id)
+	delete(shard.previous, id)
+}
+
+// Keys return the keys of session in the order they are added, which is also
+// the order session fields are written in. The order has limits:
+//   - keys set by index expressions instead of WithField, WithFields or With
+//     are the last ones in alphabetical order
+//   - keys of a Fields map passed to WithFields are added in alphabetical order
+//   - the first WithField of a key takes a lock shared by 1/16 of the sessions
+//   - the order is forgotten when the session ends, or when it is not used and
+//     more sessions are alive than SetMaxOrderedSessions allows, then all keys
+//     are in alphabetical order
+func (session Session) Keys() []string {
+	keys := make([]string, 0, len(session))
+	rangeOrdered(session, sessionOrder(sessionID(session)), func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// rangeOrdered call function with the keys and values of fields, the keys of
+// order first, then the others in alphabetical order, it returns false if
+// function stops it by returning false
+func rangeOrdered(fields map[string]interface{}, order []string, function func(key string, value interface{}) bool) bool {
+	count := 0
+	for _, key := range order {
+		if value, found := fields[key]; found {
+			count++
+			if !function(key, value) {
+				return false
+			}
+		}
+	}
+	if count == len(fields) {
+		return true
+	}
+	return rangeUnordered(fields, order, function)
+}
+
+// rangeUnordered call function with the fields not in order, in alphabetical order
+func rangeUnordered(fields map[string]interface{}, order []string, function func(key string, value interface{}) bool) bool {
+	keys := keysPool.Get().(*[]string)
+	defer func() {
+		*keys = (*keys)[:0]
+		keysPool.Put(keys)
+	}()
+	for key := range fields {
+		if !containsKey(order, key) {
+			*keys = append(*keys, key)
+		}
+	}
+	sort.Strings(*keys)
+	for _, key := range *keys {
+		if !function(key, fields[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// recordKey append key to the order of event fields, it must be called
+// before the field is added
+func (event *Event) recordKey(key string) {
+	if _, found := event.Fields[key]; found || event.typedIndex(key) >= 0 {
+		return
+	}
+	if event.order == nil {
+		event.order = event.orderBuffer[:0]
+	}
+	event.order = append(event.order, key)
+}
+
+// rangeOwnFields call function with event fields and typed fields in the
+// order they are added, index is the index of typed fields or -1 for fields
+// of event.Fields
+func (event *Event) rangeOwnFields(function func(key string, value interface{}, index int) bool) bool {
+	count := 0
+	for _, key := range event.order {
+		if i := event.typedIndex(key); i >= 0 {
+			count++
+			if !function(key, nil, i) {
+				return false
+			}
+		} else if value, found := event.Fields[key]; found {
+			count++
+			if !function(key, value, -1) {
+				return false
+			}
+		}
+	}
+	if count == len(event.Fields)+len(event.typed) {
+		return true
+	}
+	return rangeUnordered(event.Fields, event.order, func(key string, value interface{}) bool {
+		return function(key, value, -1)
+	})
+}
+
+// rangeOrderedFields call function with the merged fields of event in output
+// order, which is global fields, session fields and event fields each in the
+// order they are added, fields shadowed by later ones are skipped
+func (event *Event) rangeOrderedFields(function func(key string, value interface{}, index int) bool) {
+	if !rangeOrdered(event.globalView(), globalOrder, func(key string, value interface{}) bool {
+		if _, found := event.Session[key]; found || event.hasField(key) {
+			return true
+		}
+		return function(key, value, -1)
+	}) {
+		return
+	}
+	if len(event.Session) > 0 && !rangeOrdered(event.Session, sessionOrder(event.sessionID()), func(key string, value interface{}) bool {
+		if event.hasField(key) {
+			return true
+		}
+		return function(key, value, -1)
+	}) {
+		return
+	}
+	event.rangeOwnFields(function)
+}
@@ -0,0 +1,140 @@
+package slog
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSessionKeys(t *testing.T) {
+	session := NewSession().WithField("b", 1).WithField("a", 2).With(String("c", "3"))
+	session.WithFields(Fields{"e": 4, "d": 5}).WithField("b", 6)
+	session["z"], session["y"] = 7, 8
+	if keys := strings.Join(session.Keys(), ","); keys != "b,a,c,d,e,y,z" {
+		t.Errorf("unexpected keys: %s", keys)
+	}
+	derived := session.Derive().WithField("x", 9)
+	if keys := strings.Join(derived.Keys(), ","); keys != "b,a,c,d,e,x,y,z" {
+		t.Errorf("unexpected derived keys: %s", keys)
+	}
+	session.End()
+	if keys := strings.Join(session.Keys(), ","); keys != "a,b,c,d,e,y,z" {
+		t.Errorf("order not forgotten after End: %s", keys)
+	}
+}
+
+func TestSessionOrderLimit(t *testing.T) {
+	defer SetMaxOrderedSessions(int(atomic.LoadInt64(&maxOrderedSessions)))
+	const max = 256
+	SetMaxOrderedSessions(max)
+	// 其他测试未结束的会话会占满分片, 测试期间使用空的登记表
+	saved := make([][2]map[uintptr]*sessionOrderEntry, orderShards)
+	for i := range sessionOrderShards {
+		shard := &sessionOrderShards[i]
+		shard.Lock()
+		saved[i] = [2]map[uintptr]*sessionOrderEntry{shard.current, shard.previous}
+		shard.current, shard.previous = make(map[uintptr]*sessionOrderEntry), nil
+		shard.Unlock()
+	}
+	defer func() {
+		for i := range sessionOrderShards {
+			shard := &sessionOrderShards[i]
+			shard.Lock()
+			shard.current, shard.previous = saved[i][0], saved[i][1]
+			shard.Unlock()
+		}
+	}()
+	forgotten := loadFloat(&forgottenOrdersTotal.lookup(nil).value)
+	session := NewSession().WithField("b", 1).WithField("a", 2)
+	defer session.End()
+	others := make([]Session, 0, 8*max)
+	defer func() {
+		for _, other := range others {
+			other.End()
+		}
+	}()
+	for i := 0; i < max/2; i++ {
+		others = append(others, NewSession().WithField("key", i))
+	}
+	if len(sessionOrder(sessionID(session))) != 2 {
+		t.Error("order forgotten too early")
+	}
+	// 读取会刷新记录, 长期使用的会话不会丢失顺序
+	for i := 0; i < 4*max; i++ {
+		others = append(others, NewSession().WithField("key", i))
+		if i%8 == 0 && len(sessionOrder(sessionID(session))) != 2 {
+			t.Fatal("order of session in use forgotten")
+		}
+	}
+	// 不读取时, 足够多的其他会话使顺序被遗忘, 分片不均时需要更多会话
+	id := sessionID(session)
+	shard := orderShard(id)
+	recorded := func() bool {
+		shard.RLock()
+		defer shard.RUnlock()
+		return shard.current[id] != nil || shard.previous[id] != nil
+	}
+	for i := 0; i < 64*max && recorded(); i++ {
+		others = append(others, NewSession().WithField("key", i))
+	}
+	if keys := strings.Join(session.Keys(), ","); keys != "a,b" {
+		t.Errorf("order not forgotten: %s", keys)
+	}
+	if loadFloat(&forgottenOrdersTotal.lookup(nil).value) <= forgotten {
+		t.Error("forgotten orders not counted")
+	}
+}
+
+func TestFieldOrder(t *testing.T) {
+	defer func(fields map[string]interface{}, order []string) {
+		globalFields, globalOrder = fields, order
+	}(globalFields, globalOrder)
+	globalFields, globalOrder = make(map[string]interface{}), nil
+	WithField("service", "demo")
+	WithField("shadowed", "global")
+	WithField("env", "test")
+	session := NewSession().WithField("request_id", "1").WithField("shadowed", "session").WithField("method", "GET")
+	defer session.End()
+	event := session.Event().WithField("status", 200).With(Duration("latency", time.Second)).WithField("path", "/")
+	event.Fields["untracked"] = true
+	event.Level = infoLevel
+	event.Message = "done"
+
+	var keys []string
+	event.RangeFields(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if text := strings.Join(keys, ","); text != "service,env,request_id,shadowed,method,status,latency,path,untracked" {
+		t.Errorf("unexpected field order: %s", text)
+	}
+
+	encoder := getJSONEncoder()
+	defer putJSONEncoder(encoder)
+	content, err := encoder.encode(event, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := -1
+	for _, key := range append([]string{"timestamp", "level", "message", "caller"}, keys...) {
+		index := strings.Index(string(content), `"`+key+`":`)
+		if index <= last {
+			t.Errorf("unexpected position of %q in %s", key, content)
+		}
+		last = index
+	}
+
+	formatter := &PlainTextFormatter{EventFormat: "%(.all_fields_space_seperated_text|s)"}
+	formatted, _ := formatter.FormatEvent(event)
+	expected := `service="demo" shadowed="global" env="test" request_id="1" shadowed="session" method="GET" ` +
+		`status=200 latency=1s path="/" untracked=true`
+	if string(formatted) != expected {
+		t.Errorf("unexpected text:\n%s\n%s", formatted, expected)
+	}
+	formatter = &PlainTextFormatter{EventFormat: "%(.event_fields_comma_seperated_text|s)", SortFields: true}
+	formatted, _ = formatter.FormatEvent(event)
+	if string(formatted) != `latency=1s,path="/",status=200,untracked=true` {
+		t.Errorf("unexpected sorted text: %s", formatted)
+	}
+}
@@ -0,0 +1,195 @@
+package slog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimit configure a token bucket, Rate is tokens added per second, zero Burst means no limit
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (bucket *tokenBucket) refill(limit RateLimit, now time.Time) {
+	if bucket.last.IsZero() {
+		bucket.tokens = float64(limit.Burst)
+	} else if elapsed := now.Sub(bucket.last).Seconds(); elapsed > 0 {
+		bucket.tokens += elapsed * limit.Rate
+		if bucket.tokens > float64(limit.Burst) {
+			bucket.tokens = float64(limit.Burst)
+		}
+	}
+	bucket.last = now
+}
+
+type suppression struct {
+	caller Caller
+	count  int
+	levels map[string]int
+}
+
+// RateLimitHandler drop events exceeding the token bucket limits of their level
+// or their caller, and emit a summary event for each suppressed caller every
+// SummaryInterval.
+type RateLimitHandler struct {
+	sync.Mutex
+	Handler         Handler
+	LevelLimits     map[string]RateLimit
+	CallerLimit     RateLimit
+	SummaryInterval time.Duration
+	SummaryLevel    string
+	levelBuckets    map[string]*tokenBucket
+	callerBuckets   map[uintptr]*tokenBucket
+	suppressions    map[uintptr]*suppression
+	summarizing     bool
+	closed          bool
+	stop            chan struct{}
+}
+
+// Initialize initialize the wrapped handler
+func (handler *RateLimitHandler) Initialize() error {
+	return initializeHandler(handler.Handler)
+}
+
+// Close stop summarizing, handle the last summaries and close the wrapped handler
+func (handler *RateLimitHandler) Close() error {
+	handler.Lock()
+	summarizing := handler.summarizing && !handler.closed
+	if summarizing {
+		close(handler.stop)
+	}
+	handler.closed = true
+	handler.Unlock()
+	if summarizing {
+		for _, event := range handler.collect(time.Now()) {
+			handler.Handler.Handle(event)
+		}
+	}
+	return closeHandler(handler.Handler)
+}
+
+func (handler *RateLimitHandler) Handle(event *Event) {
+	if handler.allow(event) {
+		handler.Handler.Handle(event)
+	}
+}
+
+// Transient return true if the wrapped handler is transient
+func (handler *RateLimitHandler) Transient() bool {
+	return isTransient(handler.Handler)
+}
+
+func (handler *RateLimitHandler) allow(event *Event) bool {
+	handler.Lock()
+	defer handler.Unlock()
+	if !handler.summarizing {
+		handler.startSummary()
+	}
+	now := time.Now()
+	var levelBucket, callerBucket *tokenBucket
+	if levelLimit := handler.LevelLimits[event.Level]; levelLimit.Burst > 0 {
+		if levelBucket = handler.levelBuckets[event.Level]; levelBucket == nil {
+			levelBucket = new(tokenBucket)
+			handler.levelBuckets[event.Level] = levelBucket
+		}
+		levelBucket.refill(levelLimit, now)
+	}
+	if handler.CallerLimit.Burst > 0 {
+		if callerBucket = handler.callerBuckets[event.Caller.PC]; callerBucket == nil {
+			callerBucket = new(tokenBucket)
+			handler.callerBuckets[event.Caller.PC] = callerBucket
+		}
+		callerBucket.refill(handler.CallerLimit, now)
+	}
+	// 两个令牌桶都有余量时才放行，避免只扣除其中一个
+	if (levelBucket == nil || levelBucket.tokens >= 1) && (callerBucket == nil || callerBucket.tokens >= 1) {
+		if levelBucket != nil {
+			levelBucket.tokens--
+		}
+		if callerBucket != nil {
+			callerBucket.tokens--
+		}
+		return true
+	}
+	record := handler.suppressions[event.Caller.PC]
+	if record == nil {
+		record = &suppression{caller: event.Caller, levels: make(map[string]int)}
+		handler.suppressions[event.Caller.PC] = record
+	}
+	record.count++
+	record.levels[event.Level]++
+	suppressedEventsTotal.add(1, "RateLimitHandler")
+	return false
+}
+
+func (handler *RateLimitHandler) startSummary() {
+	if handler.SummaryInterval <= 0 {
+		handler.SummaryInterval = time.Minute
+	}
+	if handler.SummaryLevel == "" {
+		handler.SummaryLevel = warnLevel
+	}
+	handler.levelBuckets = make(map[string]*tokenBucket)
+	handler.callerBuckets = make(map[uintptr]*tokenBucket)
+	handler.suppressions = make(map[uintptr]*suppression)
+	handler.summarizing = true
+	// 关闭后不再启动汇总
+	if !handler.closed {
+		handler.stop = make(chan struct{})
+		go handler.summarize(handler.stop)
+	}
+}
+
+func (handler *RateLimitHandler) summarize(stop chan struct{}) {
+	ticker := time.NewTicker(handler.SummaryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, event := range handler.collect(now) {
+				handler.Handler.Handle(event)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// collect reset suppression records and return one summary event for each caller
+func (handler *RateLimitHandler) collect(now time.Time) []*Event {
+	handler.Lock()
+	defer handler.Unlock()
+	// 清理已回满的调用点令牌桶
+	if handler.CallerLimit.Burst > 0 {
+		for pc, bucket := range handler.callerBuckets {
+			bucket.refill(handler.CallerLimit, now)
+			if bucket.tokens >= float64(handler.CallerLimit.Burst) {
+				delete(handler.callerBuckets, pc)
+			}
+		}
+	}
+	events := make([]*Event, 0, len(handler.suppressions))
+	for pc, record := range handler.suppressions {
+		events = append(events, &Event{
+			Timestamp: now,
+			Level:     handler.SummaryLevel,
+			Message: fmt.Sprintf("suppressed %d events from %s:%d in the last %s",
+				record.count, record.caller.File, record.caller.Line, handler.SummaryInterval),
+			Fields: Fields{
+				"suppressed":        record.count,
+				"suppressed_levels": record.levels,
+				"interval":          handler.SummaryInterval.String(),
+			},
+			Caller: record.caller,
+		})
+		delete(handler.suppressions, pc)
+	}
+	return events
+}
@@ -0,0 +1,105 @@
+package slog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHandlerLevelLimit(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &RateLimitHandler{
+		Handler: receiver,
+		LevelLimits: map[string]RateLimit{
+			debugLevel: {Rate: 0.001, Burst: 3},
+		},
+	}
+	for i := 0; i < 5; i++ {
+		for _, level := range []string{debugLevel, infoLevel} {
+			event := newEvent(1, nil)
+			event.Level = level
+			handler.Handle(event)
+		}
+	}
+	if len(receiver.events) != 8 {
+		t.Error("unexpected allowed events:", len(receiver.events))
+	}
+	events := handler.collect(time.Now())
+	if len(events) != 1 {
+		t.Error("unexpected summary events:", events)
+		return
+	}
+	summary := events[0]
+	if summary.Fields["suppressed"] != 2 || summary.Caller.File != "ratelimit_handler_test.go" ||
+		!strings.HasPrefix(summary.Message, "suppressed 2 events from ratelimit_handler_test.go:") ||
+		!strings.HasSuffix(summary.Message, " in the last 1m0s") {
+		t.Error("unexpected summary event:", summary)
+	}
+	if levels, ok := summary.Fields["suppressed_levels"].(map[string]int); !ok || levels[debugLevel] != 2 {
+		t.Error("unexpected suppressed levels:", summary.Fields["suppressed_levels"])
+	}
+	if events := handler.collect(time.Now()); len(events) != 0 {
+		t.Error("unexpected summary events:", events)
+	}
+}
+
+func TestRateLimitHandlerCallerLimit(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &RateLimitHandler{
+		Handler:     receiver,
+		CallerLimit: RateLimit{Rate: 0.001, Burst: 2},
+	}
+	for i := 0; i < 3; i++ {
+		event := newEvent(1, nil)
+		event.Level = debugLevel
+		handler.Handle(event)
+	}
+	for i := 0; i < 3; i++ {
+		event := newEvent(1, nil)
+		event.Level = debugLevel
+		handler.Handle(event)
+	}
+	if len(receiver.events) != 4 {
+		t.Error("unexpected allowed events:", len(receiver.events))
+	}
+	if events := handler.collect(time.Now()); len(events) != 2 {
+		t.Error("unexpected summary events:", events)
+	}
+}
+
+func TestRateLimitHandlerSubsecondSummary(t *testing.T) {
+	handler := &RateLimitHandler{
+		Handler:         new(receiveHandler),
+		CallerLimit:     RateLimit{Rate: 0.001, Burst: 1},
+		SummaryInterval: 500 * time.Millisecond,
+	}
+	for i := 0; i < 2; i++ {
+		event := newEvent(1, nil)
+		event.Level = debugLevel
+		handler.Handle(event)
+	}
+	events := handler.collect(time.Now())
+	if len(events) != 1 || !strings.HasSuffix(events[0].Message, " in the last 500ms") {
+		t.Error("unexpected summary events:", events)
+	}
+}
+
+func TestRateLimitHandlerClose(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &RateLimitHandler{Handler: receiver, CallerLimit: RateLimit{Rate: 0.001, Burst: 1}}
+	for i := 0; i < 2; i++ {
+		event := newEvent(1, nil)
+		event.Level = debugLevel
+		handler.Handle(event)
+	}
+	handler.Close()
+	if len(receiver.events) != 2 || !strings.HasPrefix(receiver.events[1].Message, "suppressed 1 events") {
+		t.Errorf("last summary not handled: %v", receiver.events)
+	}
+	select {
+	case <-handler.stop:
+	default:
+		t.Error("summary not stopped")
+	}
+	handler.Close()
+}
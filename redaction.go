@@ -0,0 +1,321 @@
+package slog
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Masking strategies of RedactionRule
+const (
+	FullMask    = "full"
+	PartialMask = "partial"
+	HashMask    = "hash"
+)
+
+// Value patterns for RedactionRule
+const (
+	CardNumberPattern = `\b(?:\d[ -]?){12,18}\d\b`
+	EmailPattern      = `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`
+	JWTPattern        = `\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`
+)
+
+const (
+	fullMaskText    = "******"
+	maxRewriteDepth = 8
+)
+
+// RedactionRule mask values of Keys, matched case insensitively at any depth
+// of maps and structs, and parts of strings matching Pattern, including the
+// message and the text of error and fmt.Stringer values, which are replaced
+// by their redacted text. Global fields are redacted as session and event fields. Strategy "full" replace the value with asterisks, "partial" keep the
+// last Keep characters, 4 by default, and "hash" replace the value with a token
+// of HMAC-SHA256 with the hash key, so that equal values have equal tokens.
+type RedactionRule struct {
+	Keys     []string
+	Pattern  string
+	Strategy string
+	Keep     int
+}
+
+type redactor struct {
+	keys     map[string]*RedactionRule
+	patterns []*compiledRedactionPattern
+	hashKey  []byte
+}
+
+type compiledRedactionPattern struct {
+	rule    *RedactionRule
+	pattern *regexp.Regexp
+}
+
+var (
+	currentRedactor *redactor
+	// defaultHashKey is used by HashToken if redaction is disabled
+	defaultHashKey = randomHashKey()
+)
+
+func randomHashKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// HashToken return the token of text as the "hash" strategy of the current
+// redaction rules, or with a random key of the process if redaction is
+// disabled, so that values hashed outside events can be compared with the
+// redacted fields
+func HashToken(text string) string {
+	handlersLock.RLock()
+	redactor := currentRedactor
+	handlersLock.RUnlock()
+	if redactor == nil {
+		return hashToken(defaultHashKey, text)
+	}
+	return hashToken(redactor.hashKey, text)
+}
+
+func hashToken(key []byte, text string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(text))
+	return "tok_" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// SetRedaction replace the redaction rules applied to events before handlers,
+// a random hash key is used if hashKey is empty, nil rules disable redaction
+func SetRedaction(rules []RedactionRule, hashKey string) error {
+	redactor, err := newRedactor(rules, hashKey)
+	if err != nil {
+		return err
+	}
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+	currentRedactor = redactor
+	return nil
+}
+
+func newRedactor(rules []RedactionRule, hashKey string) (*redactor, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	redactor := &redactor{
+		keys:    make(map[string]*RedactionRule),
+		hashKey: []byte(hashKey),
+	}
+	if hashKey == "" {
+		redactor.hashKey = randomHashKey()
+	}
+	for i := range rules {
+		rule := &rules[i]
+		switch rule.Strategy {
+		case "", FullMask, PartialMask, HashMask:
+		default:
+			return nil, fmt.Errorf("unknown masking strategy %q", rule.Strategy)
+		}
+		for _, key := range rule.Keys {
+			if _, found := redactor.keys[strings.ToLower(key)]; !found {
+				redactor.keys[strings.ToLower(key)] = rule
+			}
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid redaction pattern %q: %s", rule.Pattern, err.Error())
+			}
+			redactor.patterns = append(redactor.patterns, &compiledRedactionPattern{rule, pattern})
+		}
+	}
+	return redactor, nil
+}
+
+// mask apply the strategy of rule to text
+func (redactor *redactor) mask(rule *RedactionRule, text string) string {
+	switch rule.Strategy {
+	case PartialMask:
+		keep := rule.Keep
+		if keep <= 0 {
+			keep = 4
+		}
+		length := utf8.RuneCountInString(text)
+		if length <= keep {
+			return fullMaskText
+		}
+		runes := []rune(text)
+		return strings.Repeat("*", length-keep) + string(runes[length-keep:])
+	case HashMask:
+		return hashToken(redactor.hashKey, text)
+	}
+	return fullMaskText
+}
+
+func (redactor *redactor) redactString(text string) string {
+	for _, pattern := range redactor.patterns {
+		text = pattern.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			return redactor.mask(pattern.rule, match)
+		})
+	}
+	return text
+}
+
+// redactText return the redacted text of value rendered as text, or value
+// itself if no pattern matches text
+func (redactor *redactor) redactText(value interface{}, text string) (interface{}, bool) {
+	if len(redactor.patterns) == 0 {
+		return value, false
+	}
+	if redacted := redactor.redactString(text); redacted != text {
+		return redacted, true
+	}
+	return value, false
+}
+
+// redactField return the redacted value of key and whether it is changed
+func (redactor *redactor) redactField(key string, value interface{}, depth int) (interface{}, bool) {
+	if rule := redactor.keys[strings.ToLower(key)]; rule != nil && value != nil {
+		return redactor.mask(rule, fmt.Sprint(value)), true
+	}
+	return redactor.redactValue(value, depth)
+}
+
+// redactValue return the redacted value and whether it is changed
+func (redactor *redactor) redactValue(value interface{}, depth int) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		redacted := redactor.redactString(v)
+		return redacted, redacted != v
+	case ErrorChain:
+		redacted, changed := v.rewriteMessages(redactor.redactString)
+		return redacted, changed
+	case error:
+		return redactor.redactText(value, v.Error())
+	case fmt.Stringer:
+		return redactor.redactText(value, v.String())
+	}
+	if depth >= maxRewriteDepth {
+		return value, false
+	}
+	return rewriteContainer(value, depth, redactor.redactField, redactor.redactValue)
+}
+
+// rewriteContainer rewrite the values of maps with string keys and exported
+// fields of structs with field, and elements of slices and arrays with element.
+// Containers with rewritten values are copied as map[string]interface{} and
+// []interface{}, other values are returned unchanged.
+func rewriteContainer(value interface{}, depth int,
+	field func(key string, value interface{}, depth int) (interface{}, bool),
+	element func(value interface{}, depth int) (interface{}, bool)) (interface{}, bool) {
+	rv := reflect.ValueOf(value)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return value, false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return value, false
+		}
+		rewritten := make(map[string]interface{}, rv.Len())
+		changed := false
+		iter := rv.MapRange()
+		for iter.Next() {
+			item, itemChanged := field(iter.Key().String(), iter.Value().Interface(), depth+1)
+			rewritten[iter.Key().String()] = item
+			changed = changed || itemChanged
+		}
+		if changed {
+			return rewritten, true
+		}
+	case reflect.Struct:
+		rewritten := make(map[string]interface{}, rv.NumField())
+		changed := false
+		for i := 0; i < rv.NumField(); i++ {
+			structField := rv.Type().Field(i)
+			if structField.PkgPath != "" {
+				continue
+			}
+			name := structField.Name
+			if tag := strings.Split(structField.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+			item, itemChanged := field(name, rv.Field(i).Interface(), depth+1)
+			rewritten[name] = item
+			changed = changed || itemChanged
+		}
+		if changed {
+			return rewritten, true
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return value, false
+		}
+		rewritten := make([]interface{}, rv.Len())
+		changed := false
+		for i := range rewritten {
+			item, itemChanged := element(rv.Index(i).Interface(), depth+1)
+			rewritten[i] = item
+			changed = changed || itemChanged
+		}
+		if changed {
+			return rewritten, true
+		}
+	}
+	return value, false
+}
+
+// redactFields return the redacted copy of fields, or fields itself if nothing is changed
+func (redactor *redactor) redactFields(fields map[string]interface{}) (map[string]interface{}, bool) {
+	var redacted map[string]interface{}
+	for key, value := range fields {
+		item, changed := redactor.redactField(key, value, 0)
+		if !changed {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(map[string]interface{}, len(fields))
+			for key, value := range fields {
+				redacted[key] = value
+			}
+		}
+		redacted[key] = item
+	}
+	if redacted == nil {
+		return fields, false
+	}
+	return redacted, true
+}
+
+// redact return the redacted copy of event, or event itself if nothing is
+// changed, the identity of the original session is kept for session states,
+// and the merged global fields seen by event are redacted as well
+func (redactor *redactor) redact(event *Event) *Event {
+	message := redactor.redactString(event.Message)
+	fields, fieldsChanged := redactor.redactFields(event.Fields)
+	session, sessionChanged := redactor.redactFields(event.Session)
+	globals, globalsChanged := redactor.redactFields(event.globalView())
+	if message == event.Message && !fieldsChanged && !sessionChanged && !globalsChanged {
+		return event
+	}
+	redacted := *event
+	redacted.Message = message
+	redacted.Fields = fields
+	if sessionChanged {
+		redacted.Session = session
+		redacted.originSession = event.origin()
+	}
+	if globalsChanged {
+		redacted.globals = globals
+	}
+	return &redacted
+}
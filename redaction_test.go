@@ -0,0 +1,123 @@
+package slog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type redactionAccount struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Card     string
+}
+
+func TestRedaction(t *testing.T) {
+	receiver := new(receiveHandler)
+	previous := SetHandlers(map[string][]Handler{})
+	defer SetHandlers(previous)
+	err := LoadConfig(Config{
+		Handlers: []HandlerConfig{{Levels: []string{"info"}, Handler: receiver}},
+		Redaction: []RedactionRule{
+			{Keys: []string{"password", "Authorization"}},
+			{Keys: []string{"user_id"}, Strategy: HashMask},
+			{Pattern: CardNumberPattern, Strategy: PartialMask},
+			{Pattern: EmailPattern, Strategy: HashMask},
+		},
+		RedactionHashKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetRedaction(nil, "")
+	session := NewSession().WithField("AUTHORIZATION", "Bearer abc")
+	session.EventSkip(1).WithFields(Fields{
+		"Password": "hunter2",
+		"user_id":  42,
+		"account":  &redactionAccount{Name: "alice", Password: "hunter2", Card: "4111 1111 1111 1111"},
+		"nested":   map[string]interface{}{"list": []interface{}{map[string]string{"password": "x"}}},
+		"plain":    "nothing",
+	}).Info("mail to alice@example.com")
+	session.EventSkip(1).WithField("user_id", 42).Info("again")
+	if len(receiver.events) != 2 {
+		t.Fatalf("unexpected events: %v", receiver.events)
+	}
+	event := receiver.events[0]
+	if event.Fields["Password"] != fullMaskText || event.Session["AUTHORIZATION"] != fullMaskText {
+		t.Errorf("keys not masked: %v %v", event.Fields, event.Session)
+	}
+	if session["AUTHORIZATION"] != "Bearer abc" {
+		t.Error("original session changed")
+	}
+	if event.sessionID() != sessionID(session) {
+		t.Error("session identity not kept")
+	}
+	token, _ := event.Fields["user_id"].(string)
+	if !strings.HasPrefix(token, "tok_") || receiver.events[1].Fields["user_id"] != token {
+		t.Errorf("unexpected tokens: %v %v", token, receiver.events[1].Fields["user_id"])
+	}
+	if HashToken("42") != token {
+		t.Errorf("unexpected token of HashToken: %s", HashToken("42"))
+	}
+	account, _ := event.Fields["account"].(map[string]interface{})
+	if account["name"] != "alice" || account["password"] != fullMaskText || account["Card"] != "***************1111" {
+		t.Errorf("unexpected account: %v", event.Fields["account"])
+	}
+	nested := event.Fields["nested"].(map[string]interface{})["list"].([]interface{})[0].(map[string]interface{})
+	if nested["password"] != fullMaskText {
+		t.Errorf("unexpected nested: %v", event.Fields["nested"])
+	}
+	if event.Fields["plain"] != "nothing" || strings.Contains(event.Message, "alice@example.com") ||
+		!strings.HasPrefix(event.Message, "mail to tok_") {
+		t.Errorf("unexpected event: %v %s", event.Fields, event.Message)
+	}
+}
+
+func TestRedactionInvalidRule(t *testing.T) {
+	if err := SetRedaction([]RedactionRule{{Pattern: "("}}, ""); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+	if err := SetRedaction([]RedactionRule{{Keys: []string{"a"}, Strategy: "unknown"}}, ""); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+}
+
+func TestRedactionUnchanged(t *testing.T) {
+	redactor, _ := newRedactor([]RedactionRule{{Keys: []string{"password"}}}, "")
+	event := newEvent(1, NewSession())
+	event.Fields["name"] = "alice"
+	if redactor.redact(event) != event {
+		t.Error("unchanged event copied")
+	}
+}
+
+type redactionStringer string
+
+func (value redactionStringer) String() string {
+	return "contact " + string(value)
+}
+
+func TestRedactionGlobalsAndText(t *testing.T) {
+	defer func(fields map[string]interface{}, order []string) {
+		globalFields, globalOrder = fields, order
+	}(globalFields, globalOrder)
+	globalFields, globalOrder = make(map[string]interface{}), nil
+	WithField("password", "secret")
+	WithField("service", "demo")
+	redactor, _ := newRedactor([]RedactionRule{{Keys: []string{"password"}}, {Pattern: EmailPattern}}, "")
+	event := newEvent(1, nil)
+	event.Fields["error"] = errors.New("no user alice@example.com")
+	event.Fields["contact"] = redactionStringer("bob@example.com")
+	event.Fields["plain"] = redactionStringer("nobody")
+	redacted := redactor.redact(event)
+	if value, _ := redacted.Field("password"); value != fullMaskText {
+		t.Errorf("global field not redacted: %v", value)
+	}
+	if globalFields["password"] != "secret" {
+		t.Error("global fields changed")
+	}
+	if redacted.Fields["error"] != "no user "+fullMaskText || redacted.Fields["contact"] != "contact "+fullMaskText ||
+		redacted.Fields["plain"] != redactionStringer("nobody") {
+		t.Errorf("unexpected fields: %v", redacted.Fields)
+	}
+}
@@ -0,0 +1,217 @@
+package slog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RingBufferHandler keep snapshots of the last Size events in memory. It is
+// also an http.Handler listing the kept events, usually mounted at /debug/slog:
+//
+//	http.Handle("/debug/slog", ringBufferHandler)
+//
+// Supported query parameters are
+//   - level: comma separated levels
+//   - since, until: RFC3339 timestamps or durations before now such as 5m
+//   - package: caller package prefix
+//   - fields.<key>: event, session or global field value
+//   - limit: max number of the most recent events
+//   - format: json (default) or text
+type RingBufferHandler struct {
+	sync.RWMutex
+	Size      int
+	Formatter *PlainTextFormatter
+	size      int
+	events    []*Event
+	next      int
+}
+
+// defaultRingBufferSize is used if Size is not set
+const defaultRingBufferSize = 1000
+
+// Initialize check Size and allocate the buffer
+func (handler *RingBufferHandler) Initialize() error {
+	handler.Lock()
+	defer handler.Unlock()
+	if handler.Size < 0 {
+		return fmt.Errorf("invalid ring buffer size %d", handler.Size)
+	}
+	handler.allocate()
+	return nil
+}
+
+// allocate the buffer once with Size, it must be called with the lock held
+func (handler *RingBufferHandler) allocate() {
+	if handler.events != nil {
+		return
+	}
+	handler.size = handler.Size
+	if handler.size <= 0 {
+		handler.size = defaultRingBufferSize
+	}
+	handler.events = make([]*Event, 0, handler.size)
+}
+
+func (handler *RingBufferHandler) Handle(event *Event) {
+	snapshot := event.snapshot()
+	handler.Lock()
+	defer handler.Unlock()
+	handler.allocate()
+	if len(handler.events) < handler.size {
+		handler.events = append(handler.events, snapshot)
+	} else {
+		handler.events[handler.next] = snapshot
+	}
+	handler.next = (handler.next + 1) % handler.size
+}
+
+// Transient return true as clones of events are kept
+func (handler *RingBufferHandler) Transient() bool {
+	return true
+}
+
+// Events return kept events from the oldest to the newest
+func (handler *RingBufferHandler) Events() []*Event {
+	handler.RLock()
+	defer handler.RUnlock()
+	events := make([]*Event, 0, len(handler.events))
+	if handler.size > 0 && len(handler.events) == handler.size {
+		events = append(events, handler.events[handler.next:]...)
+		return append(events, handler.events[:handler.next]...)
+	}
+	return append(events, handler.events...)
+}
+
+type ringBufferQuery struct {
+	levels map[string]bool
+	since  time.Time
+	until  time.Time
+	pkg    string
+	fields map[string]string
+	limit  int
+	text   bool
+}
+
+func parseRingBufferQuery(request *http.Request) (*ringBufferQuery, error) {
+	values := request.URL.Query()
+	query := &ringBufferQuery{fields: make(map[string]string)}
+	if levels := values.Get("level"); levels != "" {
+		query.levels = make(map[string]bool)
+		for _, level := range strings.Split(levels, ",") {
+			query.levels[strings.TrimSpace(level)] = true
+		}
+	}
+	var err error
+	if query.since, err = parseQueryTime(values.Get("since")); err != nil {
+		return nil, fmt.Errorf("invalid since: %s", err.Error())
+	}
+	if query.until, err = parseQueryTime(values.Get("until")); err != nil {
+		return nil, fmt.Errorf("invalid until: %s", err.Error())
+	}
+	query.pkg = values.Get("package")
+	for key := range values {
+		if strings.HasPrefix(key, "fields.") {
+			query.fields[key[len("fields."):]] = values.Get(key)
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if query.limit, err = strconv.Atoi(limit); err != nil {
+			return nil, fmt.Errorf("invalid limit: %s", err.Error())
+		}
+	}
+	switch format := values.Get("format"); format {
+	case "", "json":
+	case "text":
+		query.text = true
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return query, nil
+}
+
+// parseQueryTime parse RFC3339 timestamp or duration before now, zero time for empty string
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (query *ringBufferQuery) match(event *Event) bool {
+	if query.levels != nil && !query.levels[event.Level] {
+		return false
+	}
+	if !query.since.IsZero() && event.Timestamp.Before(query.since) {
+		return false
+	}
+	if !query.until.IsZero() && event.Timestamp.After(query.until) {
+		return false
+	}
+	if !strings.HasPrefix(event.Caller.Package, query.pkg) {
+		return false
+	}
+	for key, expected := range query.fields {
+		value, found := event.Fields[key]
+		if !found {
+			value, found = event.Session[key]
+		}
+		if !found {
+			value, found = event.globalView()[key]
+		}
+		if !found || fmt.Sprint(value) != expected {
+			return false
+		}
+	}
+	return true
+}
+
+func (handler *RingBufferHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	query, err := parseRingBufferQuery(request)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	kept := handler.Events()
+	events := make([]*Event, 0, len(kept))
+	for _, event := range kept {
+		if query.match(event) {
+			events = append(events, event)
+		}
+	}
+	if query.limit > 0 && len(events) > query.limit {
+		events = events[len(events)-query.limit:]
+	}
+	if query.text {
+		formatter := handler.Formatter
+		if formatter == nil {
+			formatter = defaultHandler.Formatter
+		}
+		content, err := formatter.FormatEvents(events)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writer.Write(content)
+		return
+	}
+	fields := make([]map[string]interface{}, len(events))
+	for i, event := range events {
+		fields[i] = event.Fieldify(time.RFC3339Nano)
+	}
+	content, err := json.Marshal(fields)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(content)
+}
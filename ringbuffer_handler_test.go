@@ -0,0 +1,77 @@
+package slog
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRingBufferHandler(t *testing.T) {
+	handler := &RingBufferHandler{Size: 3}
+	for i, level := range []string{debugLevel, infoLevel, warnLevel, errorLevel} {
+		event := newEvent(1, nil).WithField("i", i)
+		event.Level = level
+		handler.Handle(event)
+	}
+	events := handler.Events()
+	if len(events) != 3 || events[0].Level != infoLevel || events[2].Level != errorLevel {
+		t.Error("unexpected events:", events)
+	}
+	// 快照不受原事件修改影响
+	event := newEvent(1, nil).WithField("foo", "bar")
+	handler.Handle(event)
+	event.WithField("foo", "baz")
+	if events := handler.Events(); events[2].Fields["foo"] != "bar" {
+		t.Error("unexpected snapshot:", events[2])
+	}
+}
+
+func TestRingBufferHandlerInitialize(t *testing.T) {
+	if err := (&RingBufferHandler{Size: -1}).Initialize(); err == nil {
+		t.Error("negative size accepted")
+	}
+	handler := new(RingBufferHandler)
+	if err := handler.Initialize(); err != nil {
+		t.Fatal("initialize fail:", err.Error())
+	}
+	for i := 0; i < defaultRingBufferSize+1; i++ {
+		handler.Handle(newEvent(1, nil).WithField("i", i))
+	}
+	if events := handler.Events(); len(events) != defaultRingBufferSize || events[0].Fields["i"] != 1 {
+		t.Error("unexpected events:", len(events))
+	}
+}
+
+func TestRingBufferHandlerServeHTTP(t *testing.T) {
+	handler := &RingBufferHandler{Size: 10}
+	for i, level := range []string{debugLevel, infoLevel, warnLevel, errorLevel} {
+		event := newEvent(1, nil).WithField("i", i)
+		event.Level = level
+		event.Message = level
+		handler.Handle(event)
+	}
+	old := newEvent(1, nil)
+	old.Level = errorLevel
+	old.Timestamp = time.Now().Add(-time.Hour)
+	handler.Handle(old)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/slog?level=warn,error&since=10m&package=github.com/yangchenxing", nil))
+	var events []map[string]interface{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &events); err != nil {
+		t.Error("unmarshal json fail:", err.Error())
+	} else if len(events) != 2 || events[0]["level"] != warnLevel || events[1]["level"] != errorLevel {
+		t.Error("unexpected events:", events)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/slog?fields.i=1&format=text", nil))
+	if body := recorder.Body.String(); !strings.HasPrefix(body, "info") || strings.Count(body, "\n") != 1 {
+		t.Error("unexpected body:", body)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/debug/slog?limit=x", nil))
+	if recorder.Code != 400 {
+		t.Error("unexpected status:", recorder.Code)
+	}
+}
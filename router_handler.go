@@ -0,0 +1,254 @@
+package slog
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	routerPlaceholderPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+	lockerType               = reflect.TypeOf((*sync.Locker)(nil)).Elem()
+)
+
+type routedWriter struct {
+	path     string
+	writer   Writer
+	lastUsed time.Time
+}
+
+// RouterHandler write events to writers selected by Path, a template such as
+// `logs/{tenant}/app.log` whose placeholders are rendered from event fields or
+// session fields. Writers are created lazily by NewWriter, or as copies of
+// WriterTemplate with the rendered path set to their Path field. WriterTemplate
+// can be any writer of WriterFactory having a Path field, and is a daily
+// TimeRotatedFileWriter by default. At most MaxWriters writers are kept open,
+// the least recently used and the idle ones are closed. Events lacking any
+// placeholder field are sent to Fallback.
+type RouterHandler struct {
+	sync.Mutex
+	Path            string
+	Formatter       *PlainTextFormatter
+	TimestampFormat string
+	WriterTemplate  Writer
+	NewWriter       func(path string) (Writer, error)
+	MaxWriters      int
+	IdleTimeout     time.Duration
+	Fallback        Handler
+	writers         map[string]*list.Element
+	lru             *list.List
+	cleaning        bool
+	closed          bool
+	stop            chan struct{}
+}
+
+// Initialize check the writer template and initialize the fallback handler
+func (handler *RouterHandler) Initialize() error {
+	if handler.Path == "" {
+		return fmt.Errorf("router path is empty")
+	}
+	if handler.NewWriter == nil && handler.WriterTemplate != nil {
+		if _, err := copyWriterTemplate(handler.WriterTemplate, ""); err != nil {
+			return err
+		}
+	}
+	return initializeHandler(handler.Fallback)
+}
+
+// Close stop closing idle writers, close all writers and the fallback handler
+func (handler *RouterHandler) Close() error {
+	handler.Lock()
+	if handler.cleaning && !handler.closed {
+		close(handler.stop)
+	}
+	handler.closed = true
+	for handler.lru != nil && handler.lru.Len() > 0 {
+		handler.remove(handler.lru.Back())
+	}
+	handler.Unlock()
+	return closeHandler(handler.Fallback)
+}
+
+func (handler *RouterHandler) Handle(event *Event) {
+	path, ok := handler.render(event)
+	if !ok {
+		if handler.Fallback != nil {
+			handler.Fallback.Handle(event)
+		}
+		return
+	}
+	var content []byte
+	var err error
+	if handler.Formatter != nil {
+		content, err = handler.Formatter.FormatEvent(event)
+	} else {
+		encoder := getJSONEncoder()
+		defer putJSONEncoder(encoder)
+		content, err = encoder.encode(event, handler.TimestampFormat, "")
+	}
+	if err != nil {
+		reportError("router", event, &handleError{"format routed event fail", err})
+		return
+	}
+	handler.Lock()
+	defer handler.Unlock()
+	writer, err := handler.get(path)
+	if err != nil {
+		reportError("router", event, &handleError{fmt.Sprintf("create writer %q fail", path), err})
+	} else if err := writeContent(writer, content); err != nil {
+		reportError("router", event, &handleError{"write routed event fail", err})
+	}
+}
+
+// Transient return true if Fallback is transient as routed events are written before Handle returns
+func (handler *RouterHandler) Transient() bool {
+	return isTransient(handler.Fallback)
+}
+
+// render fill the path template, false if any placeholder field is missing
+func (handler *RouterHandler) render(event *Event) (string, bool) {
+	ok := true
+	path := routerPlaceholderPattern.ReplaceAllStringFunc(handler.Path, func(placeholder string) string {
+		key := placeholder[1 : len(placeholder)-1]
+		value, found := event.Fields[key]
+		if !found {
+			value, found = event.Session[key]
+		}
+		if !found || value == nil {
+			ok = false
+			return ""
+		}
+		// 字段值不能逃逸出模板指定的目录
+		text := strings.NewReplacer("/", "_", "\\", "_").Replace(fmt.Sprint(value))
+		if text == "" || text == "." || text == ".." {
+			ok = false
+		}
+		return text
+	})
+	return path, ok
+}
+
+// get return the writer of path, creating it and closing the least recently used one if needed
+func (handler *RouterHandler) get(path string) (Writer, error) {
+	if handler.writers == nil {
+		handler.writers = make(map[string]*list.Element)
+		handler.lru = list.New()
+	}
+	if !handler.cleaning && !handler.closed && handler.IdleTimeout > 0 {
+		handler.cleaning = true
+		handler.stop = make(chan struct{})
+		go handler.clean(handler.stop)
+	}
+	now := time.Now()
+	if element := handler.writers[path]; element != nil {
+		handler.lru.MoveToFront(element)
+		routed := element.Value.(*routedWriter)
+		routed.lastUsed = now
+		return routed.writer, nil
+	}
+	writer, err := handler.newWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	handler.writers[path] = handler.lru.PushFront(&routedWriter{path: path, writer: writer, lastUsed: now})
+	for handler.MaxWriters > 0 && handler.lru.Len() > handler.MaxWriters {
+		handler.remove(handler.lru.Back())
+	}
+	return writer, nil
+}
+
+func (handler *RouterHandler) newWriter(path string) (Writer, error) {
+	if handler.NewWriter != nil {
+		return handler.NewWriter(path)
+	}
+	template := handler.WriterTemplate
+	if template == nil {
+		template = new(TimeRotatedFileWriter)
+	}
+	writer, err := copyWriterTemplate(template, path)
+	if err != nil {
+		return nil, err
+	}
+	if rotated, ok := writer.(*TimeRotatedFileWriter); ok {
+		if rotated.TimestampFormat == "" {
+			rotated.TimestampFormat = "20060102"
+		}
+		if rotated.Interval <= 0 {
+			rotated.Interval = 24 * time.Hour
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// copyWriterTemplate create a writer of the template's type with its exported
+// fields except locks, and set its Path field to path
+func copyWriterTemplate(template Writer, path string) (Writer, error) {
+	value := reflect.ValueOf(template)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("writer template %T is not a struct pointer", template)
+	}
+	source := value.Elem()
+	copied := reflect.New(source.Type()).Elem()
+	for i := 0; i < source.NumField(); i++ {
+		field := source.Type().Field(i)
+		// 模板的锁和未导出的状态不复制
+		if field.PkgPath != "" || reflect.PtrTo(field.Type).Implements(lockerType) {
+			continue
+		}
+		copied.Field(i).Set(source.Field(i))
+	}
+	pathField := copied.FieldByName("Path")
+	if !pathField.IsValid() || pathField.Kind() != reflect.String {
+		return nil, fmt.Errorf("writer template %T has no Path field", template)
+	}
+	pathField.SetString(path)
+	return copied.Addr().Interface().(Writer), nil
+}
+
+func (handler *RouterHandler) remove(element *list.Element) {
+	routed := handler.lru.Remove(element).(*routedWriter)
+	delete(handler.writers, routed.path)
+	if closer, ok := routed.writer.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			reportError("router", nil, fmt.Errorf("close writer %q fail: %s", routed.path, err.Error()))
+		}
+	}
+}
+
+func (handler *RouterHandler) clean(stop chan struct{}) {
+	ticker := time.NewTicker(handler.IdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			handler.closeIdle(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// closeIdle close writers unused for IdleTimeout
+func (handler *RouterHandler) closeIdle(now time.Time) {
+	handler.Lock()
+	defer handler.Unlock()
+	for element := handler.lru.Back(); element != nil; {
+		routed := element.Value.(*routedWriter)
+		if now.Sub(routed.lastUsed) < handler.IdleTimeout {
+			break
+		}
+		previous := element.Prev()
+		handler.remove(element)
+		element = previous
+	}
+}
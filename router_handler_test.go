@@ -0,0 +1,146 @@
+package slog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type closeWriter struct {
+	bufferWriter
+	closed bool
+}
+
+func (writer *closeWriter) Close() error {
+	writer.closed = true
+	return nil
+}
+
+func TestRouterHandler(t *testing.T) {
+	writers := make(map[string]*closeWriter)
+	fallback := new(receiveHandler)
+	handler := &RouterHandler{
+		Path: "logs/{tenant}/app.log",
+		Formatter: &PlainTextFormatter{
+			EventFormat: "%(message|s)",
+		},
+		NewWriter: func(path string) (Writer, error) {
+			writer := new(closeWriter)
+			writers[path] = writer
+			return writer, nil
+		},
+		MaxWriters: 3,
+		Fallback:   fallback,
+	}
+	for _, tenant := range []string{"a", "b", "a", "c", "../etc"} {
+		event := newEvent(1, NewSession().WithField("tenant", tenant))
+		event.Message = tenant
+		handler.Handle(event)
+	}
+	handler.Handle(newEvent(1, nil))
+	if len(fallback.events) != 1 {
+		t.Error("unexpected fallback events:", fallback.events)
+	}
+	if writer := writers["logs/a/app.log"]; writer == nil || writer.String() != "aa" || writer.closed {
+		t.Error("unexpected writer of a:", writer)
+	}
+	if writer := writers["logs/b/app.log"]; writer == nil || !writer.closed {
+		t.Error("unexpected writer of b:", writer)
+	}
+	if writer := writers["logs/.._etc/app.log"]; writer == nil || writer.String() != "../etc" {
+		t.Error("unexpected writers:", writers)
+	}
+	handler.IdleTimeout = time.Minute
+	handler.closeIdle(time.Now())
+	if len(handler.writers) != 3 {
+		t.Error("unexpected idle close:", handler.writers)
+	}
+	handler.closeIdle(time.Now().Add(time.Hour))
+	if len(handler.writers) != 0 || !writers["logs/a/app.log"].closed {
+		t.Error("unexpected idle close:", handler.writers)
+	}
+}
+
+func TestRouterHandlerTimeRotatedFileWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "slog")
+	if err != nil {
+		t.Error("create temp dir fail:", err.Error())
+		return
+	}
+	defer os.RemoveAll(dir)
+	handler := &RouterHandler{
+		Path: filepath.Join(dir, "{tenant}", "app.log"),
+		WriterTemplate: &TimeRotatedFileWriter{
+			TimestampFormat: "2006010215",
+			Interval:        time.Hour,
+		},
+	}
+	event := newEvent(1, nil).WithField("tenant", "acme")
+	event.Message = "test"
+	handler.Handle(event)
+	handler.Lock()
+	handler.remove(handler.lru.Back())
+	handler.Unlock()
+	content, err := ioutil.ReadFile(filepath.Join(dir, "acme", "app.log"))
+	if err != nil {
+		t.Error("read log file fail:", err.Error())
+	} else if len(content) == 0 {
+		t.Error("empty log file")
+	}
+}
+
+type pathWriter struct {
+	sync.Mutex
+	bufferWriter
+	Path   string
+	Prefix string
+}
+
+func TestRouterHandlerWriterTemplate(t *testing.T) {
+	if err := (&RouterHandler{Path: "{tenant}", WriterTemplate: new(bufferWriter)}).Initialize(); err == nil {
+		t.Error("template without Path accepted")
+	}
+	dir, err := ioutil.TempDir("", "slog")
+	if err != nil {
+		t.Error("create temp dir fail:", err.Error())
+		return
+	}
+	defer os.RemoveAll(dir)
+	handler := &RouterHandler{
+		Path:           filepath.Join(dir, "{tenant}", "app.log"),
+		WriterTemplate: &pathWriter{Prefix: "test"},
+	}
+	if err := handler.Initialize(); err != nil {
+		t.Fatal("initialize fail:", err.Error())
+	}
+	handler.Handle(newEvent(1, nil).WithField("tenant", "acme"))
+	handler.Lock()
+	defer handler.Unlock()
+	routed := handler.lru.Front().Value.(*routedWriter).writer.(*pathWriter)
+	if routed.Path != filepath.Join(dir, "acme", "app.log") || routed.Prefix != "test" || routed.Len() == 0 {
+		t.Error("unexpected writer:", routed.Path, routed.Prefix)
+	}
+}
+
+func TestRouterHandlerClose(t *testing.T) {
+	writer := new(closeWriter)
+	handler := &RouterHandler{
+		Path:        "logs/{tenant}/app.log",
+		NewWriter:   func(path string) (Writer, error) { return writer, nil },
+		IdleTimeout: time.Hour,
+	}
+	handler.Handle(newEvent(1, NewSession().WithField("tenant", "a")))
+	handler.Close()
+	if !writer.closed || len(handler.writers) != 0 {
+		t.Error("writers not closed:", handler.writers)
+	}
+	select {
+	case <-handler.stop:
+	default:
+		t.Error("cleaning not stopped")
+	}
+	handler.Close()
+}
@@ -0,0 +1,202 @@
+package slog
+
+import (
+	"sync"
+	"time"
+)
+
+type samplingKey struct {
+	level   string
+	pc      uintptr
+	message string
+}
+
+type samplingCounter struct {
+	resetAt time.Time
+	count   int
+}
+
+// samplingDecision keep its session, so that the id of the decision is not reused
+type samplingDecision struct {
+	session  Session
+	keep     bool
+	lastSeen time.Time
+}
+
+// SamplingHandler forward the first `First` events of each key in every `Tick`,
+// then one in every `Thereafter` events. The key is level plus caller PC, and
+// also message if `KeyWithMessage` is set. With `PerSession` set, the decision
+// is made once for each session, so a session is either fully kept or fully dropped.
+type SamplingHandler struct {
+	sync.Mutex
+	Handler        Handler
+	Tick           time.Duration
+	First          int
+	Thereafter     int
+	KeyWithMessage bool
+	PerSession     bool
+	ReportInterval time.Duration
+	ReportLevel    string
+	counters       map[samplingKey]*samplingCounter
+	sessions       map[uintptr]*samplingDecision
+	sessionCounter samplingCounter
+	sampledIn      int
+	sampledOut     int
+	reporting      bool
+	closed         bool
+	stop           chan struct{}
+}
+
+// Initialize initialize the wrapped handler
+func (handler *SamplingHandler) Initialize() error {
+	return initializeHandler(handler.Handler)
+}
+
+// Close stop reporting, handle the last report and close the wrapped handler
+func (handler *SamplingHandler) Close() error {
+	handler.Lock()
+	reporting := handler.reporting && !handler.closed
+	if reporting {
+		close(handler.stop)
+	}
+	handler.closed = true
+	handler.Unlock()
+	if reporting {
+		if event := handler.collect(time.Now()); event != nil {
+			handler.Handler.Handle(event)
+		}
+	}
+	return closeHandler(handler.Handler)
+}
+
+func (handler *SamplingHandler) Handle(event *Event) {
+	if handler.sample(event) {
+		handler.Handler.Handle(event)
+	}
+}
+
+// Transient return true if the wrapped handler is transient
+func (handler *SamplingHandler) Transient() bool {
+	return isTransient(handler.Handler)
+}
+
+func (handler *SamplingHandler) sample(event *Event) bool {
+	handler.Lock()
+	defer handler.Unlock()
+	if !handler.reporting {
+		handler.startReport()
+	}
+	now := time.Now()
+	var keep bool
+	// 全局会话不参与按会话采样
+	if id := event.sessionID(); handler.PerSession && id != 0 && id != sessionID(GlobalSession) {
+		keep = handler.sampleSession(id, event.origin(), now)
+	} else {
+		key := samplingKey{level: event.Level, pc: event.Caller.PC}
+		if handler.KeyWithMessage {
+			key.message = event.Message
+		}
+		counter := handler.counters[key]
+		if counter == nil {
+			counter = new(samplingCounter)
+			handler.counters[key] = counter
+		}
+		keep = handler.check(counter, now)
+	}
+	if keep {
+		handler.sampledIn++
+	} else {
+		handler.sampledOut++
+		droppedEventsTotal.add(1, "SamplingHandler")
+	}
+	return keep
+}
+
+func (handler *SamplingHandler) sampleSession(id uintptr, session Session, now time.Time) bool {
+	decision := handler.sessions[id]
+	if decision == nil {
+		decision = &samplingDecision{session: session, keep: handler.check(&handler.sessionCounter, now)}
+		handler.sessions[id] = decision
+	}
+	decision.lastSeen = now
+	return decision.keep
+}
+
+func (handler *SamplingHandler) check(counter *samplingCounter, now time.Time) bool {
+	if !now.Before(counter.resetAt) {
+		counter.resetAt = now.Add(handler.Tick)
+		counter.count = 0
+	}
+	counter.count++
+	if counter.count <= handler.First {
+		return true
+	}
+	return handler.Thereafter > 0 && (counter.count-handler.First)%handler.Thereafter == 0
+}
+
+func (handler *SamplingHandler) startReport() {
+	if handler.Tick <= 0 {
+		handler.Tick = time.Second
+	}
+	if handler.ReportInterval <= 0 {
+		handler.ReportInterval = time.Minute
+	}
+	if handler.ReportLevel == "" {
+		handler.ReportLevel = warnLevel
+	}
+	handler.counters = make(map[samplingKey]*samplingCounter)
+	handler.sessions = make(map[uintptr]*samplingDecision)
+	handler.reporting = true
+	// 关闭后不再启动报告
+	if !handler.closed {
+		handler.stop = make(chan struct{})
+		go handler.report(handler.stop)
+	}
+}
+
+func (handler *SamplingHandler) report(stop chan struct{}) {
+	ticker := time.NewTicker(handler.ReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if event := handler.collect(now); event != nil {
+				handler.Handler.Handle(event)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// collect reset statistics and return the report event, nil if nothing was dropped
+func (handler *SamplingHandler) collect(now time.Time) *Event {
+	handler.Lock()
+	defer handler.Unlock()
+	// 清理已过期的计数器和会话决策，避免内存无限增长
+	for key, counter := range handler.counters {
+		if now.After(counter.resetAt) {
+			delete(handler.counters, key)
+		}
+	}
+	for id, decision := range handler.sessions {
+		if now.Sub(decision.lastSeen) > handler.ReportInterval {
+			delete(handler.sessions, id)
+		}
+	}
+	sampledIn, sampledOut := handler.sampledIn, handler.sampledOut
+	handler.sampledIn, handler.sampledOut = 0, 0
+	if sampledOut == 0 {
+		return nil
+	}
+	return &Event{
+		Timestamp: now,
+		Level:     handler.ReportLevel,
+		Message:   "events sampled out",
+		Fields: Fields{
+			"sampled_in":  sampledIn,
+			"sampled_out": sampledOut,
+			"interval":    handler.ReportInterval.String(),
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package slog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplingHandler(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &SamplingHandler{
+		Handler:    receiver,
+		Tick:       time.Minute,
+		First:      2,
+		Thereafter: 3,
+	}
+	for i := 0; i < 10; i++ {
+		event := newEvent(1, nil)
+		event.Level = debugLevel
+		handler.Handle(event)
+	}
+	// 保留第1、2、5、8个事件
+	if len(receiver.events) != 4 {
+		t.Error("unexpected sampled events:", len(receiver.events))
+	}
+	event := handler.collect(time.Now())
+	if event == nil {
+		t.Error("miss report event")
+	} else if event.Fields["sampled_in"] != 4 || event.Fields["sampled_out"] != 6 {
+		t.Error("unexpected report event:", event.Fields)
+	}
+	if event := handler.collect(time.Now()); event != nil {
+		t.Error("unexpected report event:", event.Fields)
+	}
+}
+
+func TestSamplingHandlerKeyWithMessage(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &SamplingHandler{
+		Handler:        receiver,
+		Tick:           time.Minute,
+		First:          1,
+		KeyWithMessage: true,
+	}
+	for i := 0; i < 4; i++ {
+		event := newEvent(1, nil)
+		event.Level = debugLevel
+		event.Message = []string{"foo", "bar"}[i%2]
+		handler.Handle(event)
+	}
+	if len(receiver.events) != 2 {
+		t.Error("unexpected sampled events:", len(receiver.events))
+	}
+}
+
+func TestSamplingHandlerPerSession(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &SamplingHandler{
+		Handler:    receiver,
+		Tick:       time.Minute,
+		First:      1,
+		PerSession: true,
+	}
+	kept, dropped := NewSession(), NewSession()
+	for i := 0; i < 3; i++ {
+		for _, session := range []Session{kept, dropped} {
+			event := newEvent(1, session)
+			event.Level = debugLevel
+			handler.Handle(event)
+		}
+	}
+	if len(receiver.events) != 3 {
+		t.Error("unexpected sampled events:", len(receiver.events))
+		return
+	}
+	for _, event := range receiver.events {
+		if sessionID(event.Session) != sessionID(kept) {
+			t.Error("unexpected session:", event.Session)
+		}
+	}
+}
+
+func TestSamplingHandlerClose(t *testing.T) {
+	receiver := new(receiveHandler)
+	handler := &SamplingHandler{Handler: receiver, First: 1, ReportInterval: time.Hour}
+	for i := 0; i < 3; i++ {
+		event := newEvent(1, nil)
+		event.Level = infoLevel
+		handler.Handle(event)
+	}
+	handler.Close()
+	if len(receiver.events) != 2 || receiver.events[1].Fields["sampled_out"] != 2 {
+		t.Errorf("last report not handled: %v", receiver.events)
+	}
+	select {
+	case <-handler.stop:
+	default:
+		t.Error("report not stopped")
+	}
+	handler.Close()
+}
@@ -2,10 +2,21 @@ package slog
 
 import (
 	"fmt"
-	"runtime/debug"
+	"reflect"
+	"sync"
 )
 
-// Session stores special fields for a session in app
+var (
+	sessionEndHooksLock sync.Mutex
+	sessionEndHooks     []*sessionEndHook
+)
+
+type sessionEndHook struct {
+	function func(Session)
+}
+
+// Session stores special fields for a session in app, the order fields are
+// added is kept with the limits described in Keys
 type Session map[string]interface{}
 
 // NewSession create a new session instance
@@ -13,14 +24,58 @@ func NewSession() Session {
 	return Session(make(map[string]interface{}))
 }
 
+// sessionID return the identity of session, 0 for nil session. The identity is
+// the address of the map, so states keyed by it keep the session as well, the
+// address is not reused by new sessions while the session is referenced.
+func sessionID(session Session) uintptr {
+	if session == nil {
+		return 0
+	}
+	return reflect.ValueOf(session).Pointer()
+}
+
+// onSessionEnd register function called when any session ends, and return
+// the function removing it
+func onSessionEnd(function func(Session)) func() {
+	hook := &sessionEndHook{function}
+	sessionEndHooksLock.Lock()
+	defer sessionEndHooksLock.Unlock()
+	sessionEndHooks = append(sessionEndHooks, hook)
+	return func() {
+		sessionEndHooksLock.Lock()
+		defer sessionEndHooksLock.Unlock()
+		// 复制后删除, End可能正在遍历旧的列表
+		hooks := make([]*sessionEndHook, 0, len(sessionEndHooks))
+		for _, h := range sessionEndHooks {
+			if h != hook {
+				hooks = append(hooks, h)
+			}
+		}
+		sessionEndHooks = hooks
+	}
+}
+
+// End notify handlers that the session is over, so that session states such
+// as buffered events can be released
+func (session Session) End() {
+	sessionEndHooksLock.Lock()
+	hooks := sessionEndHooks
+	sessionEndHooksLock.Unlock()
+	for _, hook := range hooks {
+		hook.function(session)
+	}
+}
+
 // WithField add a key value pair to session
 func (session Session) WithField(key string, value interface{}) Session {
+	recordSessionKeys(session, key)
 	session[key] = value
 	return session
 }
 
 // WithFields add multiple key value pairs to session
 func (session Session) WithFields(fields Fields) Session {
+	recordSessionKeys(session, sortedKeys(fields)...)
 	for key, value := range fields {
 		session[key] = value
 	}
@@ -37,119 +92,164 @@ func (session Session) EventSkip(skip int) *Event {
 	return newEvent(skip+1, session)
 }
 
+// EventAt create a new event of session with level, to be written by Print,
+// Printf or Println. A no-op event is returned if level is not enabled, so that
+// building it costs almost nothing. Fields added to the no-op event are dropped,
+// but Panic on it still throws an event of session.
+func (session Session) EventAt(level string) *Event {
+	if level != "panic" && !Enabled(level) {
+		if sessionID(session) == sessionID(GlobalSession) {
+			return disabledEvent
+		}
+		return &Event{Session: session, disabled: true}
+	}
+	event := session.EventSkip(2)
+	event.Level = level
+	return event
+}
+
+// pooledEventSkip create a pooled event, see newPooledEvent
+func (session Session) pooledEventSkip(skip int) *Event {
+	return newPooledEvent(skip+1, session)
+}
+
 // Debug log `debug` event with fmt.Sprint
 func (session Session) Debug(args ...interface{}) {
-	session.EventSkip(2).Log(debugLevel, args...)
+	if Enabled(debugLevel) {
+		session.pooledEventSkip(2).Log(debugLevel, args...)
+	}
 }
 
 // Debugf log `debug` event with fmt.Sprintf
 func (session Session) Debugf(format string, args ...interface{}) {
-	session.EventSkip(2).Logf(debugLevel, format, args...)
+	if Enabled(debugLevel) {
+		session.pooledEventSkip(2).Logf(debugLevel, format, args...)
+	}
 }
 
 // Debugln log `debug` event with fmt.Sprintln
 func (session Session) Debugln(args ...interface{}) {
-	session.EventSkip(2).Logln(debugLevel, args...)
+	if Enabled(debugLevel) {
+		session.pooledEventSkip(2).Logln(debugLevel, args...)
+	}
 }
 
 // Info log `info` event with fmt.Sprint
 func (session Session) Info(args ...interface{}) {
-	session.EventSkip(2).Log(infoLevel, args...)
+	if Enabled(infoLevel) {
+		session.pooledEventSkip(2).Log(infoLevel, args...)
+	}
 }
 
 // Infof log `info` event with fmt.Sprintf
 func (session Session) Infof(format string, args ...interface{}) {
-	session.EventSkip(2).Logf(infoLevel, format, args...)
+	if Enabled(infoLevel) {
+		session.pooledEventSkip(2).Logf(infoLevel, format, args...)
+	}
 }
 
 // Infoln log `info` event with fmt.Sprintln
 func (session Session) Infoln(args ...interface{}) {
-	session.EventSkip(2).Logln(infoLevel, args...)
+	if Enabled(infoLevel) {
+		session.pooledEventSkip(2).Logln(infoLevel, args...)
+	}
 }
 
 // Warn log `warn` event with fmt.Sprint
 func (session Session) Warn(args ...interface{}) {
-	session.EventSkip(2).Log(warnLevel, args...)
+	if Enabled(warnLevel) {
+		session.pooledEventSkip(2).Log(warnLevel, args...)
+	}
 }
 
 // Warnf log `warn` event with fmt.Sprintf
 func (session Session) Warnf(format string, args ...interface{}) {
-	session.EventSkip(2).Logf(warnLevel, format, args...)
+	if Enabled(warnLevel) {
+		session.pooledEventSkip(2).Logf(warnLevel, format, args...)
+	}
 }
 
 // Warnln log `warn` event with fmt.Sprintln
 func (session Session) Warnln(args ...interface{}) {
-	session.EventSkip(2).Logln(warnLevel, args...)
+	if Enabled(warnLevel) {
+		session.pooledEventSkip(2).Logln(warnLevel, args...)
+	}
 }
 
 // Error log `error` event with fmt.Sprint
 func (session Session) Error(args ...interface{}) {
-	session.EventSkip(2).Log(errorLevel, args...)
+	if Enabled(errorLevel) {
+		session.pooledEventSkip(2).Log(errorLevel, args...)
+	}
 }
 
 // Errorf log `error` event with fmt.Sprintf
 func (session Session) Errorf(format string, args ...interface{}) {
-	session.EventSkip(2).Logf(errorLevel, format, args...)
+	if Enabled(errorLevel) {
+		session.pooledEventSkip(2).Logf(errorLevel, format, args...)
+	}
 }
 
 // Errorln log `error` event with fmt.Sprintln
 func (session Session) Errorln(args ...interface{}) {
-	session.EventSkip(2).Logln(errorLevel, args...)
+	if Enabled(errorLevel) {
+		session.pooledEventSkip(2).Logln(errorLevel, args...)
+	}
 }
 
 // Fatal log `fatal` event with fmt.Sprint
 func (session Session) Fatal(args ...interface{}) {
-	session.EventSkip(2).Log(fatalLevel, args...)
+	if Enabled(fatalLevel) {
+		session.pooledEventSkip(2).Log(fatalLevel, args...)
+	}
 }
 
 // Fatalf log `fatal` event with fmt.Sprintf
 func (session Session) Fatalf(format string, args ...interface{}) {
-	session.EventSkip(2).Logf(fatalLevel, format, args...)
+	if Enabled(fatalLevel) {
+		session.pooledEventSkip(2).Logf(fatalLevel, format, args...)
+	}
 }
 
 // Fatalln log `fatal` event with fmt.Sprintln
 func (session Session) Fatalln(args ...interface{}) {
-	session.EventSkip(2).Logln(fatalLevel, args...)
+	if Enabled(fatalLevel) {
+		session.pooledEventSkip(2).Logln(fatalLevel, args...)
+	}
 }
 
 // Panic throw `panic` event with fmt.Sprint
 func (session Session) Panic(args ...interface{}) {
-	event := session.EventSkip(2)
-	event.Level = "panic"
-	event.Message = fmt.Sprint(args...)
-	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	session.EventSkip(2).throw(fmt.Sprint(args...))
 }
 
 // Panicf throw `panic` event with fmt.Sprintf
 func (session Session) Panicf(format string, args ...interface{}) {
-	event := session.EventSkip(2)
-	event.Level = "panic"
-	event.Message = fmt.Sprintf(format, args...)
-	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	session.EventSkip(2).throw(fmt.Sprintf(format, args...))
 }
 
 // Panicln throw `panic` event with fmt.Sprintln
 func (session Session) Panicln(args ...interface{}) {
-	event := session.EventSkip(2)
-	event.Level = "panic"
-	event.Message = fmt.Sprintln(args...)
-	event.WithField(stackKey, string(debug.Stack()))
-	panic(event)
+	session.EventSkip(2).throw(fmt.Sprintln(args...))
 }
 
 // Log write event with customized level and fmt.Sprint
 func (session Session) Log(level string, args ...interface{}) {
-	session.EventSkip(2).Log(level, args...)
+	if Enabled(level) {
+		session.pooledEventSkip(2).Log(level, args...)
+	}
 }
 
 // Logf write event with customized level and fmt.Sprintf
 func (session Session) Logf(level string, format string, args ...interface{}) {
-	session.EventSkip(2).Logf(level, format, args...)
+	if Enabled(level) {
+		session.pooledEventSkip(2).Logf(level, format, args...)
+	}
 }
 
 // Logln write event with customized level and fmt.Sprintln
 func (session Session) Logln(level string, args ...interface{}) {
-	session.EventSkip(2).Logln(level, args...)
+	if Enabled(level) {
+		session.pooledEventSkip(2).Logln(level, args...)
+	}
 }
@@ -335,9 +335,9 @@ func TestSessionPanic(t *testing.T) {
 	defer func() {
 		if event := recover(); event == nil {
 			t.Error("no panic")
-		} else if event, ok := event.(*Event); !ok {
+		} else if err, ok := event.(*PanicError); !ok {
 			t.Error("invalid panic type:", event)
-		} else if event.Level != "panic" || event.Message != fmt.Sprint("test", "event") {
+		} else if event := err.Event; event.Level != "panic" || event.Message != fmt.Sprint("test", "event") {
 			t.Error("unexpected event:", event)
 		} else if _, found := event.Fields[stackKey]; !found {
 			t.Error("miss stack")
@@ -351,9 +351,9 @@ func TestSessionPanicf(t *testing.T) {
 	defer func() {
 		if event := recover(); event == nil {
 			t.Error("no panic")
-		} else if event, ok := event.(*Event); !ok {
+		} else if err, ok := event.(*PanicError); !ok {
 			t.Error("invalid panic type:", event)
-		} else if event.Level != "panic" || event.Message != fmt.Sprintf("test %s", "event") {
+		} else if event := err.Event; event.Level != "panic" || event.Message != fmt.Sprintf("test %s", "event") {
 			t.Error("unexpected event:", event)
 		} else if _, found := event.Fields[stackKey]; !found {
 			t.Error("miss stack")
@@ -367,9 +367,9 @@ func TestSessionPanicln(t *testing.T) {
 	defer func() {
 		if event := recover(); event == nil {
 			t.Error("no panic")
-		} else if event, ok := event.(*Event); !ok {
+		} else if err, ok := event.(*PanicError); !ok {
 			t.Error("invalid panic type:", event)
-		} else if event.Level != "panic" || event.Message != fmt.Sprintln("test", "event") {
+		} else if event := err.Event; event.Level != "panic" || event.Message != fmt.Sprintln("test", "event") {
 			t.Error("unexpected event:", event)
 		} else if _, found := event.Fields[stackKey]; !found {
 			t.Error("miss stack")
@@ -0,0 +1,368 @@
+// Package sloggrpc provide gRPC interceptors logging calls with sessions of package slog.
+//
+//	interceptor := &sloggrpc.Interceptor{LogPayloads: true}
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(interceptor.UnaryServer),
+//		grpc.StreamInterceptor(interceptor.StreamServer))
+package sloggrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yangchenxing/go-slog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var defaultCodeLevels = map[codes.Code]string{
+	codes.OK:                 "info",
+	codes.Canceled:           "warn",
+	codes.InvalidArgument:    "warn",
+	codes.NotFound:           "warn",
+	codes.AlreadyExists:      "warn",
+	codes.PermissionDenied:   "warn",
+	codes.Unauthenticated:    "warn",
+	codes.FailedPrecondition: "warn",
+	codes.OutOfRange:         "warn",
+	codes.ResourceExhausted:  "warn",
+	codes.Aborted:            "warn",
+}
+
+// Interceptor log gRPC calls. Each call gets a session derived from Session,
+// or the session in the context for client calls, with method, peer and
+// request_id fields, where the request id is read from metadata RequestIDKey,
+// the session of client calls or generated, and client calls send it in the
+// outgoing metadata. Client streams are completed by the error of RecvMsg, or
+// its first message if the server does not stream, and abandoned ones are
+// logged when their context is done. Server sessions are stored in the call
+// context and can be got with slog.FromContext, and call sessions are ended
+// after their completion is logged. Completed calls are logged with code and latency_ms
+// fields at the level of CodeLevels keyed by code name such as "NotFound",
+// which defaults to info for OK, error for server side failures and warn for
+// the others. Payloads are logged as JSON if LogPayloads is set, with values
+// of RedactFields replaced and truncated to MaxPayloadBytes.
+type Interceptor struct {
+	Session         slog.Session
+	RequestIDKey    string
+	CodeLevels      map[string]string
+	LogPayloads     bool
+	MaxPayloadBytes int
+	RedactFields    []string
+}
+
+func (interceptor *Interceptor) requestIDKey() string {
+	if interceptor.RequestIDKey == "" {
+		return "x-request-id"
+	}
+	return strings.ToLower(interceptor.RequestIDKey)
+}
+
+func (interceptor *Interceptor) level(code codes.Code) string {
+	if level := interceptor.CodeLevels[code.String()]; level != "" {
+		return level
+	}
+	if level := defaultCodeLevels[code]; level != "" {
+		return level
+	}
+	return "error"
+}
+
+// serverSession derive the session of an incoming call
+func (interceptor *Interceptor) serverSession(ctx context.Context, method string) slog.Session {
+	session := interceptor.Session.Derive()
+	session.WithField("method", method)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		session.WithField("peer", p.Addr.String())
+	}
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(interceptor.requestIDKey()); len(values) > 0 {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = slog.NewTraceID()
+	}
+	session.WithField("request_id", requestID)
+	return session
+}
+
+// clientSession derive the session of an outgoing call and propagate its request id
+func (interceptor *Interceptor) clientSession(ctx context.Context, method string, cc *grpc.ClientConn) (context.Context, slog.Session) {
+	base := interceptor.Session
+	if base == nil {
+		base = slog.FromContext(ctx)
+	}
+	session := base.Derive()
+	session.WithField("method", method)
+	if cc != nil {
+		session.WithField("peer", cc.Target())
+	}
+	requestID, _ := session["request_id"].(string)
+	propagated := false
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if values := md.Get(interceptor.requestIDKey()); len(values) > 0 {
+			requestID = values[0]
+			propagated = true
+		}
+	}
+	if requestID == "" {
+		requestID = slog.NewTraceID()
+	}
+	if !propagated {
+		ctx = metadata.AppendToOutgoingContext(ctx, interceptor.requestIDKey(), requestID)
+	}
+	session.WithField("request_id", requestID)
+	return ctx, session
+}
+
+func (interceptor *Interceptor) complete(session slog.Session, start time.Time, err error, fields slog.Fields) {
+	code := status.Code(err)
+	event := session.EventSkip(1).WithFields(fields).WithFields(slog.Fields{
+		"code":       code.String(),
+		"latency_ms": float64(time.Since(start)) / float64(time.Millisecond),
+	})
+	if err != nil {
+		event.WithError(err)
+	}
+	event.Log(interceptor.level(code), "grpc call completed")
+}
+
+// payload render message as truncated and redacted JSON
+func (interceptor *Interceptor) payload(message interface{}) string {
+	content, err := json.Marshal(message)
+	if err != nil {
+		return "unmarshalable payload: " + err.Error()
+	}
+	if len(interceptor.RedactFields) > 0 {
+		var value interface{}
+		if json.Unmarshal(content, &value) == nil {
+			if redacted, err := json.Marshal(interceptor.redact(value)); err == nil {
+				content = redacted
+			}
+		}
+	}
+	maxBytes := interceptor.MaxPayloadBytes
+	if maxBytes <= 0 {
+		maxBytes = 4096
+	}
+	if len(content) > maxBytes {
+		return string(content[:maxBytes]) + "...(truncated)"
+	}
+	return string(content)
+}
+
+func (interceptor *Interceptor) redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			redacted := false
+			for _, field := range interceptor.RedactFields {
+				if strings.EqualFold(key, field) {
+					v[key] = "[REDACTED]"
+					redacted = true
+					break
+				}
+			}
+			if !redacted {
+				v[key] = interceptor.redact(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = interceptor.redact(item)
+		}
+	}
+	return value
+}
+
+// UnaryServer is a grpc.UnaryServerInterceptor
+func (interceptor *Interceptor) UnaryServer(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	session := interceptor.serverSession(ctx, info.FullMethod)
+	defer session.End()
+	resp, err := handler(slog.NewContext(ctx, session), req)
+	fields := slog.Fields{}
+	if interceptor.LogPayloads {
+		fields["request"] = interceptor.payload(req)
+		if err == nil {
+			fields["response"] = interceptor.payload(resp)
+		}
+	}
+	interceptor.complete(session, start, err, fields)
+	return resp, err
+}
+
+// UnaryClient is a grpc.UnaryClientInterceptor
+func (interceptor *Interceptor) UnaryClient(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	ctx, session := interceptor.clientSession(ctx, method, cc)
+	defer session.End()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	fields := slog.Fields{}
+	if interceptor.LogPayloads {
+		fields["request"] = interceptor.payload(req)
+		if err == nil {
+			fields["response"] = interceptor.payload(reply)
+		}
+	}
+	interceptor.complete(session, start, err, fields)
+	return err
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	interceptor *Interceptor
+	ctx         context.Context
+	session     slog.Session
+	received    atomic.Int64
+	sent        atomic.Int64
+}
+
+func (stream *serverStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *serverStream) SendMsg(message interface{}) error {
+	err := stream.ServerStream.SendMsg(message)
+	if err == nil {
+		stream.sent.Add(1)
+		stream.interceptor.logMessage(stream.session, "grpc stream message sent", message)
+	}
+	return err
+}
+
+func (stream *serverStream) RecvMsg(message interface{}) error {
+	err := stream.ServerStream.RecvMsg(message)
+	if err == nil {
+		stream.received.Add(1)
+		stream.interceptor.logMessage(stream.session, "grpc stream message received", message)
+	}
+	return err
+}
+
+func (interceptor *Interceptor) logMessage(session slog.Session, message string, payload interface{}) {
+	if interceptor.LogPayloads {
+		session.EventSkip(1).WithField("payload", interceptor.payload(payload)).Debug(message)
+	}
+}
+
+// StreamServer is a grpc.StreamServerInterceptor
+func (interceptor *Interceptor) StreamServer(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	session := interceptor.serverSession(ss.Context(), info.FullMethod)
+	defer session.End()
+	stream := &serverStream{
+		ServerStream: ss,
+		interceptor:  interceptor,
+		ctx:          slog.NewContext(ss.Context(), session),
+		session:      session,
+	}
+	err := handler(srv, stream)
+	interceptor.complete(session, start, err, slog.Fields{
+		"messages_received": stream.received.Load(),
+		"messages_sent":     stream.sent.Load(),
+	})
+	return err
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	interceptor   *Interceptor
+	session       slog.Session
+	start         time.Time
+	serverStreams bool
+	received      atomic.Int64
+	sent          atomic.Int64
+	completed     sync.Once
+	done          chan struct{}
+}
+
+func (stream *clientStream) SendMsg(message interface{}) error {
+	err := stream.ClientStream.SendMsg(message)
+	if err == nil {
+		stream.sent.Add(1)
+		stream.interceptor.logMessage(stream.session, "grpc stream message sent", message)
+	} else if err != io.EOF {
+		// io.EOF表示服务端已结束流, 状态由RecvMsg获得
+		stream.complete(err)
+	}
+	return err
+}
+
+func (stream *clientStream) RecvMsg(message interface{}) error {
+	err := stream.ClientStream.RecvMsg(message)
+	if err == nil {
+		stream.received.Add(1)
+		stream.interceptor.logMessage(stream.session, "grpc stream message received", message)
+		if !stream.serverStreams {
+			// 非服务端流式调用在收到唯一的响应后结束
+			stream.complete(nil)
+		}
+	} else {
+		stream.complete(err)
+	}
+	return err
+}
+
+// complete log the stream and end its session once it ends, io.EOF from
+// RecvMsg means success
+func (stream *clientStream) complete(err error) {
+	stream.completed.Do(func() {
+		if err == io.EOF {
+			err = nil
+		}
+		stream.interceptor.complete(stream.session, stream.start, err, slog.Fields{
+			"messages_received": stream.received.Load(),
+			"messages_sent":     stream.sent.Load(),
+		})
+		stream.session.End()
+		close(stream.done)
+	})
+}
+
+// watch log the stream abandoned without reading its end when ctx is done
+func (stream *clientStream) watch(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		code := codes.Canceled
+		if ctx.Err() == context.DeadlineExceeded {
+			code = codes.DeadlineExceeded
+		}
+		stream.complete(status.Error(code, ctx.Err().Error()))
+	case <-stream.done:
+	}
+}
+
+// StreamClient is a grpc.StreamClientInterceptor
+func (interceptor *Interceptor) StreamClient(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+	ctx, session := interceptor.clientSession(ctx, method, cc)
+	cs, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		interceptor.complete(session, start, err, nil)
+		session.End()
+		return nil, err
+	}
+	stream := &clientStream{
+		ClientStream:  cs,
+		interceptor:   interceptor,
+		session:       session,
+		start:         start,
+		serverStreams: desc.ServerStreams,
+		done:          make(chan struct{}),
+	}
+	if ctx.Done() != nil {
+		go stream.watch(ctx)
+	}
+	return stream, nil
+}
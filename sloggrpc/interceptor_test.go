@@ -0,0 +1,256 @@
+package sloggrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/yangchenxing/go-slog"
+	"github.com/yangchenxing/go-slog/slogtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type testRequest struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Comment  string `json:"text"`
+}
+
+func TestUnaryServer(t *testing.T) {
+	recorder := slogtest.Capture(t)
+	interceptor := &Interceptor{LogPayloads: true, RedactFields: []string{"Password"}, MaxPayloadBytes: 80}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-1"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+	var session slog.Session
+	_, err := interceptor.UnaryServer(ctx, &testRequest{User: "alice", Password: "secret", Comment: strings.Repeat("x", 100)},
+		&grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			session = slog.FromContext(ctx)
+			return nil, status.Error(codes.NotFound, "missing")
+		})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if session["request_id"] != "req-1" || session["method"] != "/test.Service/Get" || session["peer"] != "10.0.0.1:1234" {
+		t.Errorf("unexpected session: %v", session)
+	}
+	events := recorder.Find("warn", "grpc call completed", nil)
+	if len(events) != 1 {
+		t.Fatalf("completion event not logged: %v", recorder.Events())
+	}
+	event := events[0]
+	if event.Fields["code"] != "NotFound" {
+		t.Errorf("unexpected code: %v", event.Fields["code"])
+	}
+	request, _ := event.Fields["request"].(string)
+	if strings.Contains(request, "secret") || !strings.Contains(request, "[REDACTED]") || !strings.HasSuffix(request, "...(truncated)") {
+		t.Errorf("unexpected request payload: %s", request)
+	}
+	if _, found := event.Fields["response"]; found {
+		t.Error("response of failed call logged")
+	}
+}
+
+func TestUnaryClient(t *testing.T) {
+	recorder := slogtest.Capture(t)
+	interceptor := &Interceptor{CodeLevels: map[string]string{"OK": "debug"}}
+	var requestID []string
+	err := interceptor.UnaryClient(context.Background(), "/test.Service/Get", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			requestID = md.Get("x-request-id")
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requestID) != 1 || requestID[0] == "" {
+		t.Fatalf("request id not propagated: %v", requestID)
+	}
+	events := recorder.Find("debug", "grpc call completed", nil)
+	if len(events) != 1 {
+		t.Fatalf("completion event not logged: %v", recorder.Events())
+	}
+	event := events[0]
+	if event.Session["request_id"] != requestID[0] || event.Fields["code"] != "OK" {
+		t.Errorf("unexpected event: %v %v", event.Session, event.Fields)
+	}
+}
+
+func TestCodeLevels(t *testing.T) {
+	interceptor := &Interceptor{CodeLevels: map[string]string{"NotFound": "info"}}
+	cases := map[codes.Code]string{
+		codes.OK:          "info",
+		codes.NotFound:    "info",
+		codes.Canceled:    "warn",
+		codes.Internal:    "error",
+		codes.Unavailable: "error",
+	}
+	for code, level := range cases {
+		if actual := interceptor.level(code); actual != level {
+			t.Errorf("level of %s: expect %s, got %s", code, level, actual)
+		}
+	}
+}
+
+func TestUnaryClientSessionRequestID(t *testing.T) {
+	slogtest.Capture(t)
+	session := slog.NewSession().WithField("request_id", "req-2")
+	interceptor := &Interceptor{}
+	var requestID []string
+	interceptor.UnaryClient(slog.NewContext(context.Background(), session), "/test.Service/Get", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			md, _ := metadata.FromOutgoingContext(ctx)
+			requestID = md.Get("x-request-id")
+			return nil
+		})
+	if len(requestID) != 1 || requestID[0] != "req-2" {
+		t.Errorf("request id of session not propagated: %v", requestID)
+	}
+}
+
+type testClientStream struct {
+	grpc.ClientStream
+	sendErr  error
+	recvErrs []error
+}
+
+func (stream *testClientStream) SendMsg(message interface{}) error {
+	return stream.sendErr
+}
+
+func (stream *testClientStream) RecvMsg(message interface{}) error {
+	if len(stream.recvErrs) == 0 {
+		return io.EOF
+	}
+	err := stream.recvErrs[0]
+	stream.recvErrs = stream.recvErrs[1:]
+	return err
+}
+
+func newTestClientStream(t *testing.T, interceptor *Interceptor, desc *grpc.StreamDesc, cs *testClientStream) *clientStream {
+	stream, err := interceptor.StreamClient(context.Background(), desc, nil, "/test.Service/Stream",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return cs, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream.(*clientStream)
+}
+
+func TestStreamClientClientStreaming(t *testing.T) {
+	recorder := slogtest.Capture(t)
+	interceptor := &Interceptor{}
+	stream := newTestClientStream(t, interceptor, &grpc.StreamDesc{ClientStreams: true}, &testClientStream{recvErrs: []error{nil}})
+	stream.SendMsg("first")
+	stream.SendMsg("second")
+	if err := stream.RecvMsg(new(string)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stream.done:
+	default:
+		t.Fatal("client streaming call not completed by its response")
+	}
+	events := recorder.Find("info", "grpc call completed", slog.Fields{"code": "OK", "messages_sent": 2, "messages_received": 1})
+	if len(events) != 1 {
+		t.Errorf("client streaming call not logged: %v", recorder.Events())
+	}
+}
+
+func TestStreamClientBidi(t *testing.T) {
+	recorder := slogtest.Capture(t)
+	interceptor := &Interceptor{}
+	cs := &testClientStream{sendErr: io.EOF, recvErrs: []error{nil, status.Error(codes.Unavailable, "gone")}}
+	stream := newTestClientStream(t, interceptor, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, cs)
+	if err := stream.SendMsg("first"); err != io.EOF {
+		t.Fatalf("unexpected send error: %v", err)
+	}
+	stream.RecvMsg(new(string))
+	if events := recorder.Find("", "grpc call completed", nil); len(events) != 0 {
+		t.Fatalf("stream completed before its status: %v", events)
+	}
+	if err := stream.RecvMsg(new(string)); status.Code(err) != codes.Unavailable {
+		t.Fatalf("unexpected receive error: %v", err)
+	}
+	events := recorder.Find("error", "grpc call completed", slog.Fields{"code": "Unavailable", "messages_received": 1})
+	if len(events) != 1 {
+		t.Errorf("bidi stream not logged with its status: %v", recorder.Events())
+	}
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int
+}
+
+func (stream *testServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *testServerStream) SendMsg(message interface{}) error {
+	return nil
+}
+
+func (stream *testServerStream) RecvMsg(message interface{}) error {
+	if stream.received == 2 {
+		return io.EOF
+	}
+	stream.received++
+	return nil
+}
+
+func TestStreamServer(t *testing.T) {
+	recorder := slogtest.Capture(t)
+	interceptor := &Interceptor{LogPayloads: true}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "req-3"))
+	var session slog.Session
+	err := interceptor.StreamServer(nil, &testServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Chat"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			session = slog.FromContext(stream.Context())
+			for stream.RecvMsg(new(string)) == nil {
+			}
+			return stream.SendMsg("done")
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session["request_id"] != "req-3" || session["method"] != "/test.Service/Chat" {
+		t.Errorf("unexpected session: %v", session)
+	}
+	if events := recorder.Find("debug", "grpc stream message received", nil); len(events) != 2 {
+		t.Errorf("unexpected message events: %v", recorder.Events())
+	}
+	events := recorder.Find("info", "grpc call completed", slog.Fields{"code": "OK", "messages_sent": 1, "messages_received": 2})
+	if len(events) != 1 {
+		t.Errorf("stream not logged: %v", recorder.Events())
+	}
+}
+
+func TestStreamClientAbandoned(t *testing.T) {
+	recorder := slogtest.Capture(t)
+	interceptor := &Interceptor{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cs, err := interceptor.StreamClient(ctx, &grpc.StreamDesc{}, nil, "/test.Service/Watch",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &testClientStream{}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.SendMsg("first")
+	cancel()
+	<-cs.(*clientStream).done
+	events := recorder.Find("warn", "grpc call completed", slog.Fields{"code": "Canceled", "messages_sent": 1})
+	if len(events) != 1 {
+		t.Errorf("abandoned stream not logged: %v", recorder.Events())
+	}
+}
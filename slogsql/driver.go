@@ -0,0 +1,392 @@
+// Package slogsql wrap database/sql drivers to log statements with sessions of package slog.
+//
+//	slogsql.Register("mysql-logged", &slogsql.Driver{
+//		Driver:        &mysql.MySQLDriver{},
+//		SlowThreshold: 200 * time.Millisecond,
+//		Arguments:     slogsql.HashArguments,
+//	})
+//	db, err := sql.Open("mysql-logged", dsn)
+//	rows, err := db.QueryContext(slog.NewContext(ctx, session), query, args...)
+package slogsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/yangchenxing/go-slog"
+)
+
+// Argument logging modes of Driver
+const (
+	LogArguments    = "log"
+	RedactArguments = "redact"
+	HashArguments   = "hash"
+)
+
+var packagePrefix = reflect.TypeOf(Driver{}).PkgPath() + "."
+
+// Driver wrap Driver to log connects, queries, execs, transactions and their
+// errors with duration field, and rows_affected field for execs. Events are
+// logged with the session of the context passed to database/sql, or
+// slog.GlobalSession for calls without context, at Level which defaults to
+// debug, at SlowLevel which defaults to warn if the duration reaches
+// SlowThreshold, and at error level on errors. Arguments are not logged unless
+// Arguments is "log", "redact" for placeholders or "hash" for the tokens of
+// slog.HashToken, keyed HMAC-SHA256 digests which can be compared without
+// exposing the values. The duration of
+// queries is the time until rows are returned, not including the iteration.
+type Driver struct {
+	Driver        driver.Driver
+	Level         string
+	SlowThreshold time.Duration
+	SlowLevel     string
+	Arguments     string
+}
+
+// Register register the wrapped driver to database/sql as name
+func Register(name string, d *Driver) {
+	sql.Register(name, d)
+}
+
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	start := time.Now()
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		d.log(context.Background(), "sql connect", "", nil, start, err, nil)
+		return nil, err
+	}
+	return &conn{Conn: c, driver: d}, nil
+}
+
+// OpenConnector implement driver.DriverContext so that connects are logged with context
+func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &connector{connector: c, driver: d}, nil
+	}
+	return &connector{name: name, driver: d}, nil
+}
+
+type connector struct {
+	connector driver.Connector
+	name      string
+	driver    *Driver
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	start := time.Now()
+	var dc driver.Conn
+	var err error
+	if c.connector != nil {
+		dc, err = c.connector.Connect(ctx)
+	} else {
+		dc, err = c.driver.Driver.Open(c.name)
+	}
+	if err != nil {
+		c.driver.log(ctx, "sql connect", "", nil, start, err, nil)
+		return nil, err
+	}
+	return &conn{Conn: dc, driver: c.driver}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// level return the level of a statement finished in duration
+func (d *Driver) level(duration time.Duration) string {
+	if d.SlowThreshold > 0 && duration >= d.SlowThreshold {
+		if d.SlowLevel != "" {
+			return d.SlowLevel
+		}
+		return "warn"
+	}
+	if d.Level != "" {
+		return d.Level
+	}
+	return "debug"
+}
+
+func (d *Driver) log(ctx context.Context, message, query string, args []driver.NamedValue, start time.Time, err error, fields slog.Fields) {
+	duration := time.Since(start)
+	event := slog.FromContext(ctx).EventSkip(callerSkip())
+	event.WithFields(fields).WithField("duration", duration)
+	if query != "" {
+		event.WithField("query", query)
+	}
+	if len(args) > 0 && d.Arguments != "" {
+		event.WithField("args", d.arguments(args))
+	}
+	if err != nil {
+		event.WithError(err).Log("error", message)
+		return
+	}
+	event.Log(d.level(duration), message)
+}
+
+// callerSkip return the skip of the first caller outside this package and
+// database/sql for slog.Session.EventSkip called by the caller of callerSkip
+func callerSkip() int {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	skip := 2
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, packagePrefix) && !strings.HasSuffix(frame.File, "_test.go")
+		if !internal && !strings.HasPrefix(frame.Function, "database/sql.") {
+			return skip
+		}
+		if !more {
+			return 2
+		}
+		skip++
+	}
+}
+
+func (d *Driver) arguments(args []driver.NamedValue) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch d.Arguments {
+		case LogArguments:
+			values[i] = arg.Value
+		case HashArguments:
+			if bytes, ok := arg.Value.([]byte); ok {
+				values[i] = slog.HashToken(string(bytes))
+			} else {
+				values[i] = slog.HashToken(fmt.Sprint(arg.Value))
+			}
+		default:
+			values[i] = "?"
+		}
+	}
+	return values
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, value := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: value}
+	}
+	return named
+}
+
+func values(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("driver does not support named arguments")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func rowsAffected(result driver.Result) slog.Fields {
+	if result == nil {
+		return nil
+	}
+	if rows, err := result.RowsAffected(); err == nil {
+		return slog.Fields{"rows_affected": rows}
+	}
+	return nil
+}
+
+type conn struct {
+	driver.Conn
+	driver *Driver
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	var s driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		s, err = preparer.PrepareContext(ctx, query)
+	} else {
+		s, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		c.driver.log(ctx, "sql prepare", query, nil, start, err, nil)
+		return nil, err
+	}
+	return &stmt{Stmt: s, driver: c.driver, query: query}, nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, options driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+	var t driver.Tx
+	var err error
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		t, err = beginner.BeginTx(ctx, options)
+	} else if options.Isolation != driver.IsolationLevel(sql.LevelDefault) || options.ReadOnly {
+		err = fmt.Errorf("driver does not support non-default transaction options")
+	} else {
+		t, err = c.Conn.Begin()
+	}
+	c.driver.log(ctx, "sql begin", "", nil, start, err, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: t, driver: c.driver, ctx: ctx}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if execer, ok := c.Conn.(driver.ExecerContext); ok {
+		result, err = execer.ExecContext(ctx, query, args)
+	} else if execer, ok := c.Conn.(driver.Execer); ok {
+		var dargs []driver.Value
+		if dargs, err = values(args); err == nil {
+			result, err = execer.Exec(query, dargs)
+		}
+	} else {
+		return nil, driver.ErrSkip
+	}
+	// ErrSkip表示database/sql将改用Prepare执行, 届时再记录
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	c.driver.log(ctx, "sql exec", query, args, start, err, rowsAffected(result))
+	return result, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok {
+		rows, err = queryer.QueryContext(ctx, query, args)
+	} else if queryer, ok := c.Conn.(driver.Queryer); ok {
+		var dargs []driver.Value
+		if dargs, err = values(args); err == nil {
+			rows, err = queryer.Query(query, dargs)
+		}
+	} else {
+		return nil, driver.ErrSkip
+	}
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	c.driver.log(ctx, "sql query", query, args, start, err, nil)
+	return rows, err
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type stmt struct {
+	driver.Stmt
+	driver *Driver
+	query  string
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if execer, ok := s.Stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var dargs []driver.Value
+		if dargs, err = values(args); err == nil {
+			result, err = s.Stmt.Exec(dargs)
+		}
+	}
+	s.driver.log(ctx, "sql exec", s.query, args, start, err, rowsAffected(result))
+	return result, err
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValues(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var dargs []driver.Value
+		if dargs, err = values(args); err == nil {
+			rows, err = s.Stmt.Query(dargs)
+		}
+	}
+	s.driver.log(ctx, "sql query", s.query, args, start, err, nil)
+	return rows, err
+}
+
+func (s *stmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type tx struct {
+	driver.Tx
+	driver *Driver
+	ctx    context.Context
+}
+
+func (t *tx) Commit() error {
+	start := time.Now()
+	err := t.Tx.Commit()
+	t.driver.log(t.ctx, "sql commit", "", nil, start, err, nil)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	start := time.Now()
+	err := t.Tx.Rollback()
+	t.driver.log(t.ctx, "sql rollback", "", nil, start, err, nil)
+	return err
+}
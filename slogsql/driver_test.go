@@ -0,0 +1,166 @@
+package slogsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yangchenxing/go-slog"
+	"github.com/yangchenxing/go-slog/slogtest"
+)
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (fakeConn) Close() error {
+	return nil
+}
+
+func (fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "FAIL") {
+		return nil, errors.New("syntax error")
+	}
+	if strings.Contains(query, "SLOW") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return driver.RowsAffected(3), nil
+}
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (fakeRows) Close() error {
+	return nil
+}
+
+func (fakeRows) Next(dest []driver.Value) error {
+	return io.EOF
+}
+
+func init() {
+	Register("slogsql-test", &Driver{
+		Driver:        fakeDriver{},
+		SlowThreshold: 10 * time.Millisecond,
+		Arguments:     HashArguments,
+	})
+}
+
+func TestDriver(t *testing.T) {
+	recorder := slogtest.Capture(t)
+	db, err := sql.Open("slogsql-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	session := slog.NewSession().WithField("request_id", "req-1")
+	ctx := slog.NewContext(context.Background(), session)
+
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = ?", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	events := recorder.Find("debug", "sql exec", slog.Fields{"rows_affected": int64(3)})
+	if len(events) != 1 {
+		t.Fatalf("exec not logged: %v", recorder.Events())
+	}
+	event := events[0]
+	if event.Session["request_id"] != "req-1" || event.Fields["query"] != "UPDATE users SET name = ?" {
+		t.Errorf("unexpected event: %v %v", event.Session, event.Fields)
+	}
+	if _, ok := event.Fields["duration"].(time.Duration); !ok {
+		t.Errorf("unexpected duration: %v", event.Fields["duration"])
+	}
+	args := event.Fields["args"].([]interface{})
+	if len(args) != 1 || !strings.HasPrefix(args[0].(string), "tok_") {
+		t.Errorf("unexpected args: %v", args)
+	}
+	if event.Caller.File != "driver_test.go" {
+		t.Errorf("unexpected caller: %+v", event.Caller)
+	}
+
+	if _, err := db.ExecContext(ctx, "UPDATE SLOW"); err != nil {
+		t.Fatal(err)
+	}
+	slogtest.AssertLogged(t, "warn", "sql exec", slog.Fields{"query": "UPDATE SLOW"})
+	if _, err := db.ExecContext(ctx, "FAIL"); err == nil {
+		t.Fatal("expect error")
+	}
+	slogtest.AssertLogged(t, "error", "sql exec", slog.Fields{"error": "syntax error"})
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	slogtest.AssertLogged(t, "debug", "sql query", slog.Fields{"query": "SELECT id FROM users"})
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	slogtest.AssertLogged(t, "debug", "sql begin", nil)
+	events = recorder.Find("debug", "sql commit", nil)
+	if len(events) != 1 || events[0].Session["request_id"] != "req-1" {
+		t.Errorf("commit not logged with session: %v", events)
+	}
+}
+
+func TestArguments(t *testing.T) {
+	args := []driver.NamedValue{{Ordinal: 1, Value: "secret"}}
+	if values := (&Driver{Arguments: LogArguments}).arguments(args); values[0] != "secret" {
+		t.Errorf("unexpected logged argument: %v", values)
+	}
+	if values := (&Driver{Arguments: RedactArguments}).arguments(args); values[0] != "?" {
+		t.Errorf("unexpected redacted argument: %v", values)
+	}
+	first := (&Driver{Arguments: HashArguments}).arguments(args)
+	second := (&Driver{Arguments: HashArguments}).arguments(args)
+	if first[0] != second[0] || first[0] != slog.HashToken("secret") {
+		t.Errorf("unexpected hashed argument: %v %v", first, second)
+	}
+	// 摘要带密钥, 不同密钥的摘要不同
+	if err := slog.SetRedaction([]slog.RedactionRule{{Keys: []string{"password"}}}, "key"); err != nil {
+		t.Fatal(err)
+	}
+	defer slog.SetRedaction(nil, "")
+	if keyed := (&Driver{Arguments: HashArguments}).arguments(args); keyed[0] == first[0] {
+		t.Errorf("hashed argument not keyed: %v", keyed)
+	}
+}
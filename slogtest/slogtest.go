@@ -0,0 +1,265 @@
+// Package slogtest capture log events of package slog in tests.
+//
+//	func TestSomething(t *testing.T) {
+//		recorder := slogtest.Capture(t)
+//		doSomething()
+//		slogtest.AssertLogged(t, "error", "timeout", slog.Fields{"tenant": "acme"})
+//		recorder.Reset()
+//	}
+//
+// Handlers are global in package slog. Events of sessions created by
+// Recorder.Session are recorded only by that recorder, other events are
+// recorded by all active recorders, including events logged by other parallel
+// tests, so parallel tests should log with the sessions of their recorders.
+package slogtest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yangchenxing/go-slog"
+)
+
+// Levels are the levels recorded by Capture besides the extra ones passed to it
+var Levels = []string{"debug", "info", "warn", "error", "fatal", "panic"}
+
+var (
+	lock      sync.Mutex
+	recorders = make(map[testing.TB]*Recorder)
+	previous  map[string][]slog.Handler
+	installed map[string][]slog.Handler
+	active    []*Recorder
+)
+
+// Recorder keep snapshots of recorded events
+type Recorder struct {
+	sync.Mutex
+	events   []*slog.Event
+	sessions []slog.Session
+}
+
+type dispatcher struct{}
+
+// Handle record event by the recorder of its session, or by all active
+// recorders if its session is not created by any of them
+func (dispatcher) Handle(event *slog.Event) {
+	lock.Lock()
+	targets := active
+	lock.Unlock()
+	for _, recorder := range targets {
+		if recorder.owns(event) {
+			recorder.record(event)
+			return
+		}
+	}
+	for _, recorder := range targets {
+		recorder.record(event)
+	}
+}
+
+// Transient return true as recorders keep clones of events
+func (dispatcher) Transient() bool {
+	return true
+}
+
+// Capture install a recorder for levels in Levels and extra levels until the test
+// finishes, the previous handlers are restored when the last capture finishes.
+func Capture(t testing.TB, levels ...string) *Recorder {
+	t.Helper()
+	recorder := new(Recorder)
+	lock.Lock()
+	defer lock.Unlock()
+	if _, found := recorders[t]; found {
+		t.Fatal("slogtest: Capture called twice in one test")
+	}
+	newHandlers := make(map[string][]slog.Handler)
+	for _, level := range append(append([]string{}, Levels...), levels...) {
+		newHandlers[level] = []slog.Handler{dispatcher{}}
+	}
+	if len(active) == 0 {
+		installed = newHandlers
+		previous = slog.SetHandlers(installed)
+	} else if len(levels) > 0 {
+		installed = mergeHandlers(installed, newHandlers)
+		slog.SetHandlers(installed)
+	}
+	recorders[t] = recorder
+	active = append(active, recorder)
+	t.Cleanup(func() {
+		lock.Lock()
+		defer lock.Unlock()
+		delete(recorders, t)
+		for i, r := range active {
+			if r == recorder {
+				active = append(active[:i:i], active[i+1:]...)
+				break
+			}
+		}
+		if len(active) == 0 {
+			slog.SetHandlers(previous)
+			previous, installed = nil, nil
+		}
+	})
+	return recorder
+}
+
+func mergeHandlers(handlers, extra map[string][]slog.Handler) map[string][]slog.Handler {
+	merged := make(map[string][]slog.Handler, len(handlers)+len(extra))
+	for level, levelHandlers := range handlers {
+		merged[level] = levelHandlers
+	}
+	for level, levelHandlers := range extra {
+		merged[level] = levelHandlers
+	}
+	return merged
+}
+
+// Session create a session whose events are recorded only by recorder,
+// sessions derived from it are not routed
+func (recorder *Recorder) Session() slog.Session {
+	session := slog.NewSession()
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.sessions = append(recorder.sessions, session)
+	return session
+}
+
+func (recorder *Recorder) owns(event *slog.Event) bool {
+	recorder.Lock()
+	defer recorder.Unlock()
+	for _, session := range recorder.sessions {
+		if event.InSession(session) {
+			return true
+		}
+	}
+	return false
+}
+
+func (recorder *Recorder) record(event *slog.Event) {
+	snapshot := event.Clone()
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.events = append(recorder.events, snapshot)
+}
+
+// Events return recorded events in order
+func (recorder *Recorder) Events() []*slog.Event {
+	recorder.Lock()
+	defer recorder.Unlock()
+	return append([]*slog.Event{}, recorder.events...)
+}
+
+// Reset drop recorded events
+func (recorder *Recorder) Reset() {
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.events = nil
+}
+
+// Find return recorded events of level, empty level for any level, whose message
+// contains msgSubstring and whose event or session fields contain fields
+func (recorder *Recorder) Find(level, msgSubstring string, fields slog.Fields) []*slog.Event {
+	events := make([]*slog.Event, 0, 1)
+	for _, event := range recorder.Events() {
+		if match(event, level, msgSubstring, fields) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// AssertLogged fail the test if no matching event is recorded, see Find
+func (recorder *Recorder) AssertLogged(t testing.TB, level, msgSubstring string, fields slog.Fields) {
+	t.Helper()
+	if len(recorder.Find(level, msgSubstring, fields)) == 0 {
+		t.Errorf("slogtest: no %s event containing %q with fields %v logged, recorded events:\n%s",
+			describeLevel(level), msgSubstring, fields, recorder.dump())
+	}
+}
+
+// AssertNotLogged fail the test if any matching event is recorded, see Find
+func (recorder *Recorder) AssertNotLogged(t testing.TB, level, msgSubstring string, fields slog.Fields) {
+	t.Helper()
+	if events := recorder.Find(level, msgSubstring, fields); len(events) > 0 {
+		t.Errorf("slogtest: unexpected %s event containing %q with fields %v logged: %s",
+			describeLevel(level), msgSubstring, fields, format(events[0]))
+	}
+}
+
+func (recorder *Recorder) dump() string {
+	events := recorder.Events()
+	lines := make([]string, 0, len(events))
+	for _, event := range events {
+		lines = append(lines, "\t"+format(event))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lookup return the recorder captured by t, subtests must call Capture
+// themselves or use the Recorder of the capturing test
+func lookup(t testing.TB) *Recorder {
+	t.Helper()
+	lock.Lock()
+	recorder := recorders[t]
+	lock.Unlock()
+	if recorder == nil {
+		t.Fatal("slogtest: Capture is not called by this test, subtests must call Capture or use the Recorder of their parent")
+	}
+	return recorder
+}
+
+// Events return events recorded by the capture of t
+func Events(t testing.TB) []*slog.Event {
+	t.Helper()
+	return lookup(t).Events()
+}
+
+// AssertLogged fail the test if no matching event is recorded by the capture of t
+func AssertLogged(t testing.TB, level, msgSubstring string, fields slog.Fields) {
+	t.Helper()
+	lookup(t).AssertLogged(t, level, msgSubstring, fields)
+}
+
+// AssertNotLogged fail the test if any matching event is recorded by the capture of t
+func AssertNotLogged(t testing.TB, level, msgSubstring string, fields slog.Fields) {
+	t.Helper()
+	lookup(t).AssertNotLogged(t, level, msgSubstring, fields)
+}
+
+func match(event *slog.Event, level, msgSubstring string, fields slog.Fields) bool {
+	if level != "" && event.Level != level {
+		return false
+	}
+	if !strings.Contains(event.Message, msgSubstring) {
+		return false
+	}
+	for key, expected := range fields {
+		value, found := event.Fields[key]
+		if !found {
+			value, found = event.Session[key]
+		}
+		if !found || !equal(value, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// equal compare values deeply, or by their text for values such as int and int64
+func equal(value, expected interface{}) bool {
+	return reflect.DeepEqual(value, expected) || fmt.Sprint(value) == fmt.Sprint(expected)
+}
+
+func describeLevel(level string) string {
+	if level == "" {
+		return "any level"
+	}
+	return level
+}
+
+func format(event *slog.Event) string {
+	return fmt.Sprintf("%s %q fields=%v session=%v", event.Level, event.Message, event.Fields, event.Session)
+}
@@ -0,0 +1,75 @@
+package slogtest
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/yangchenxing/go-slog"
+)
+
+type fakeT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.failed = true
+}
+
+func (t *fakeT) Fatal(args ...interface{}) {
+	t.failed = true
+	runtime.Goexit()
+}
+
+func TestCapture(t *testing.T) {
+	recorder := Capture(t, "audit")
+	slog.NewSession().WithField("tenant", "acme").Event().WithField("retry", 3).Error("connect timeout")
+	slog.GlobalSession.Log("audit", "login")
+	AssertLogged(t, "error", "timeout", slog.Fields{"tenant": "acme", "retry": int64(3)})
+	AssertLogged(t, "", "login", nil)
+	AssertNotLogged(t, "warn", "", nil)
+	if events := Events(t); len(events) != 2 || events[0].Caller.Func != "TestCapture" {
+		t.Error("unexpected events:", events)
+	}
+	fake := &fakeT{TB: t}
+	recorder.AssertLogged(fake, "error", "refused", nil)
+	if !fake.failed {
+		t.Error("unexpected success")
+	}
+	fake = &fakeT{TB: t}
+	recorder.AssertNotLogged(fake, "error", "", slog.Fields{"tenant": "acme"})
+	if !fake.failed {
+		t.Error("unexpected success")
+	}
+	recorder.Reset()
+	if events := recorder.Events(); len(events) != 0 {
+		t.Error("unexpected events:", events)
+	}
+	fake = &fakeT{TB: t}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Events(fake)
+	}()
+	<-done
+	if !fake.failed {
+		t.Error("events of other tests returned")
+	}
+}
+
+func TestCaptureParallel(t *testing.T) {
+	for _, name := range []string{"a", "b", "c"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			recorder := Capture(t)
+			recorder.Session().WithField("name", name).Info("parallel")
+			AssertLogged(t, "info", "parallel", slog.Fields{"name": name})
+			if events := recorder.Events(); len(events) != 1 {
+				t.Error("events of other tests recorded:", events)
+			}
+		})
+	}
+}
@@ -34,6 +34,11 @@ type TimeRotatedFileWriter struct {
 	servingStopped  chan time.Time
 }
 
+// Transient return true as content is written to the file before Write returns
+func (writer *TimeRotatedFileWriter) Transient() bool {
+	return true
+}
+
 func (writer *TimeRotatedFileWriter) Write(content []byte) error {
 	if writer.file == nil {
 		if err := writer.open(); err != nil {
@@ -48,6 +53,31 @@ func (writer *TimeRotatedFileWriter) Write(content []byte) error {
 	return err
 }
 
+// Close stop rotating and close current file, the file will be reopened on next write
+func (writer *TimeRotatedFileWriter) Close() error {
+	if writer.serving {
+		writer.stopServe()
+	}
+	writer.Lock()
+	defer writer.Unlock()
+	writer.serving = false
+	if writer.file == nil {
+		return nil
+	}
+	err := writer.file.Close()
+	writer.file = nil
+	return err
+}
+
+// Probe check the file can be opened for writing
+func (writer *TimeRotatedFileWriter) Probe() error {
+	file, err := os.OpenFile(writer.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 func (writer *TimeRotatedFileWriter) open() error {
 	writer.Lock()
 	defer writer.Unlock()
@@ -95,10 +125,10 @@ ForLoop:
 		case <-time.After(nextTimestamp.Sub(time.Now())):
 			// 切割和清理同周期进行
 			if err := writer.rotate(currentTimestamp); err != nil {
-				fmt.Fprintf(os.Stderr, "rotate log file %q fail: %s\n", writer.Path, err.Error())
+				reportError("time_rotated_file", nil, fmt.Errorf("rotate log file %q fail: %s", writer.Path, err.Error()))
 			}
 			if err := writer.clean(currentTimestamp); err != nil {
-				fmt.Fprintf(os.Stderr, "clean log file %q fail: %s\n", writer.Path, err.Error())
+				reportError("time_rotated_file", nil, fmt.Errorf("clean log file %q fail: %s", writer.Path, err.Error()))
 			}
 			currentTimestamp = nextTimestamp
 		case <-writer.servingStop:
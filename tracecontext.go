@@ -0,0 +1,168 @@
+package slog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Field keys of W3C trace context in sessions
+const (
+	TraceIDKey      = "trace_id"
+	SpanIDKey       = "span_id"
+	ParentSpanIDKey = "parent_span_id"
+	TraceFlagsKey   = "trace_flags"
+)
+
+// TraceParentHeader is the W3C trace context header
+const TraceParentHeader = "traceparent"
+
+// Trace field conventions for TraceConvention of formatters
+const (
+	ElasticTraceConvention       = "elastic"
+	OpenTelemetryTraceConvention = "otel"
+)
+
+var traceConventions = map[string]map[string]string{
+	ElasticTraceConvention: {
+		TraceIDKey:      "trace.id",
+		SpanIDKey:       "span.id",
+		ParentSpanIDKey: "parent.id",
+		TraceFlagsKey:   "trace.flags",
+	},
+	OpenTelemetryTraceConvention: {
+		TraceIDKey:      "traceId",
+		SpanIDKey:       "spanId",
+		ParentSpanIDKey: "parentSpanId",
+		TraceFlagsKey:   "traceFlags",
+	},
+}
+
+// TraceParent is a parsed traceparent header
+type TraceParent struct {
+	TraceID  string
+	ParentID string
+	Flags    string
+}
+
+// ParseTraceParent parse a traceparent header value
+func ParseTraceParent(value string) (TraceParent, error) {
+	var parent TraceParent
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return parent, fmt.Errorf("invalid traceparent %q", value)
+	}
+	version := parts[0]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return parent, fmt.Errorf("invalid traceparent version %q", version)
+	}
+	parent.TraceID, parent.ParentID, parent.Flags = parts[1], parts[2], parts[3]
+	if !isLowerHex(parent.TraceID, 32) || parent.TraceID == strings.Repeat("0", 32) {
+		return parent, fmt.Errorf("invalid trace id %q", parent.TraceID)
+	}
+	if !isLowerHex(parent.ParentID, 16) || parent.ParentID == strings.Repeat("0", 16) {
+		return parent, fmt.Errorf("invalid parent id %q", parent.ParentID)
+	}
+	if !isLowerHex(parent.Flags, 2) {
+		return parent, fmt.Errorf("invalid trace flags %q", parent.Flags)
+	}
+	return parent, nil
+}
+
+func isLowerHex(text string, length int) bool {
+	if len(text) != length {
+		return false
+	}
+	for _, c := range text {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// NewTraceID generate a random 16 bytes trace id in hex
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+// NewSpanID generate a random 8 bytes span id in hex
+func NewSpanID() string {
+	return randomHex(8)
+}
+
+func randomHex(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Derive create a new session with a copy of fields of session
+func (session Session) Derive() Session {
+	derived := make(Session, len(session))
+	for key, value := range session {
+		derived[key] = value
+	}
+	if order := sessionOrder(sessionID(session)); len(order) > 0 {
+		recordSessionKeys(derived, order...)
+	}
+	return derived
+}
+
+// WithTraceParent derive a session for a new span from the traceparent header
+// value of the incoming request, a new trace is started if the value is empty
+// or invalid.
+func (session Session) WithTraceParent(value string) Session {
+	derived := session.Derive()
+	delete(derived, ParentSpanIDKey)
+	if parent, err := ParseTraceParent(value); err == nil {
+		derived.WithField(TraceIDKey, parent.TraceID)
+		derived.WithField(SpanIDKey, NewSpanID())
+		derived.WithField(ParentSpanIDKey, parent.ParentID)
+		derived.WithField(TraceFlagsKey, parent.Flags)
+	} else {
+		derived.WithField(TraceIDKey, NewTraceID())
+		derived.WithField(SpanIDKey, NewSpanID())
+		derived.WithField(TraceFlagsKey, "01")
+	}
+	return derived
+}
+
+// WithTraceContext derive a session for a new span from the traceparent header of request
+func (session Session) WithTraceContext(request *http.Request) Session {
+	return session.WithTraceParent(request.Header.Get(TraceParentHeader))
+}
+
+// TraceParent return the traceparent header value of session, empty string if session has no trace
+func (session Session) TraceParent() string {
+	traceID, _ := session[TraceIDKey].(string)
+	spanID, _ := session[SpanIDKey].(string)
+	if traceID == "" || spanID == "" {
+		return ""
+	}
+	flags, _ := session[TraceFlagsKey].(string)
+	if flags == "" {
+		flags = "01"
+	}
+	return "00-" + traceID + "-" + spanID + "-" + flags
+}
+
+// InjectTraceContext write traceparent header of session to the outgoing request
+func (session Session) InjectTraceContext(request *http.Request) {
+	if value := session.TraceParent(); value != "" {
+		request.Header.Set(TraceParentHeader, value)
+	}
+}
+
+// renameTraceFields move trace fields to the names of convention
+func renameTraceFields(fields map[string]interface{}, convention string) {
+	names := traceConventions[convention]
+	for key, name := range names {
+		if value, found := fields[key]; found {
+			delete(fields, key)
+			fields[name] = value
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package slog
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	parent, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Error("parse fail:", err.Error())
+	} else if parent.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || parent.ParentID != "00f067aa0ba902b7" || parent.Flags != "01" {
+		t.Error("unexpected trace parent:", parent)
+	}
+	if _, err := ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future"); err != nil {
+		t.Error("parse future version fail:", err.Error())
+	}
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	} {
+		if _, err := ParseTraceParent(value); err == nil {
+			t.Errorf("unexpected success: %q\n", value)
+		}
+	}
+}
+
+func TestSessionTraceContext(t *testing.T) {
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	parent := NewSession().WithField("app", "test")
+	session := parent.WithTraceContext(request)
+	if len(parent) != 1 {
+		t.Error("parent session changed:", parent)
+	}
+	if session["app"] != "test" || session[TraceIDKey] != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		session[ParentSpanIDKey] != "00f067aa0ba902b7" || session[TraceFlagsKey] != "01" ||
+		!isLowerHex(session[SpanIDKey].(string), 16) || session[SpanIDKey] == "00f067aa0ba902b7" {
+		t.Error("unexpected session:", session)
+	}
+	outgoing := httptest.NewRequest("GET", "/", nil)
+	session.InjectTraceContext(outgoing)
+	if value := outgoing.Header.Get(TraceParentHeader); value != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+session[SpanIDKey].(string)+"-01" {
+		t.Error("unexpected traceparent:", value)
+	}
+	session = NewSession().WithTraceParent("invalid")
+	if !isLowerHex(session[TraceIDKey].(string), 32) || session[ParentSpanIDKey] != nil {
+		t.Error("unexpected new trace session:", session)
+	}
+}
+
+func TestJsonHandlerTraceConvention(t *testing.T) {
+	session := NewSession().WithTraceParent("")
+	event := newEvent(1, session)
+	writer := new(bufferWriter)
+	handler := &JsonHandler{
+		TraceConvention: ElasticTraceConvention,
+		Writer:          writer,
+	}
+	handler.Handle(event)
+	var fields map[string]interface{}
+	if err := json.Unmarshal(writer.Bytes(), &fields); err != nil {
+		t.Error("unmarshal json fail:", err.Error())
+	} else if fields["trace.id"] != session[TraceIDKey] || fields["span.id"] != session[SpanIDKey] || fields[TraceIDKey] != nil {
+		t.Error("unexpected fields:", fields)
+	}
+}
+
+func TestPlainTextFormatterTraceConvention(t *testing.T) {
+	session := NewSession().WithTraceParent("")
+	event := newEvent(1, session).WithField(TraceIDKey, "event")
+	formatter := &PlainTextFormatter{
+		EventFormat:     defaultHandler.Formatter.EventFormat,
+		TraceConvention: ElasticTraceConvention,
+	}
+	content, _ := formatter.FormatEvent(event)
+	text := string(content)
+	if !strings.Contains(text, `trace.id="`+session[TraceIDKey].(string)+`"`) ||
+		!strings.Contains(text, `span.id="`+session[SpanIDKey].(string)+`"`) ||
+		!strings.Contains(text, `trace.flags="01"`) || !strings.Contains(text, `trace.id="event"`) ||
+		strings.Contains(text, TraceIDKey+"=") || strings.Contains(text, SpanIDKey+"=") {
+		t.Error("trace fields not renamed:", text)
+	}
+}
@@ -0,0 +1,101 @@
+package slog
+
+import (
+	"fmt"
+)
+
+// maxValuerDepth limit the resolution of valuers returning valuers
+const maxValuerDepth = 8
+
+// Valuer is implemented by field values computed when the event is handled,
+// LogValue is called at most once per event and only if any handler handles it
+type Valuer interface {
+	LogValue() interface{}
+}
+
+type lazyValue struct {
+	function func() interface{}
+}
+
+func (value *lazyValue) LogValue() interface{} {
+	return value.function()
+}
+
+// Lazy create a Valuer computing its value by function
+func Lazy(function func() interface{}) Valuer {
+	return &lazyValue{function}
+}
+
+// valuerPanic replace the value of a Valuer panicking in LogValue
+type valuerPanic struct {
+	value interface{}
+}
+
+func (err *valuerPanic) Error() string {
+	return fmt.Sprintf("LogValue panic: %v", err.value)
+}
+
+func (err *valuerPanic) MarshalText() ([]byte, error) {
+	return []byte(err.Error()), nil
+}
+
+// resolveValue return the value of valuer, or value itself if it is not a Valuer
+func resolveValue(value interface{}) interface{} {
+	for i := 0; i < maxValuerDepth; i++ {
+		valuer, ok := value.(Valuer)
+		if !ok {
+			return value
+		}
+		value = callValuer(valuer)
+	}
+	return value
+}
+
+// globalView return the global fields seen by event, which are resolved by
+// write if they have Valuers
+func (event *Event) globalView() map[string]interface{} {
+	if event.globals != nil {
+		return event.globals
+	}
+	return globalFields
+}
+
+func callValuer(valuer Valuer) (value interface{}) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			value = &valuerPanic{recovered}
+		}
+	}()
+	return valuer.LogValue()
+}
+
+// resolveValuers replace Valuer values of event fields and session with their
+// values, the session is copied with its identity kept, and global fields
+// with Valuers are resolved once into the globals of event
+func (event *Event) resolveValuers() {
+	if globalValuers && event.globals == nil {
+		globals := make(map[string]interface{}, len(globalFields))
+		for key, value := range globalFields {
+			globals[key] = resolveValue(value)
+		}
+		event.globals = globals
+	}
+	for key, value := range event.Fields {
+		if _, ok := value.(Valuer); ok {
+			event.Fields[key] = resolveValue(value)
+		}
+	}
+	var session Session
+	for key, value := range event.Session {
+		if _, ok := value.(Valuer); ok {
+			if session == nil {
+				session = event.Session.Derive()
+			}
+			session[key] = resolveValue(value)
+		}
+	}
+	if session != nil {
+		event.originSession = event.origin()
+		event.Session = session
+	}
+}
@@ -0,0 +1,87 @@
+package slog
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	handler1 := new(receiveHandler)
+	handler2 := new(receiveHandler)
+	handlers = map[string][]Handler{
+		infoLevel: []Handler{handler1, handler2},
+	}
+	calls := 0
+	lazy := Lazy(func() interface{} {
+		calls++
+		return "computed"
+	})
+	session := NewSession().WithField("lazy", lazy)
+	session.EventSkip(1).WithField("body", lazy).Debug("disabled")
+	if calls != 0 {
+		t.Fatalf("valuer of disabled level called %d times", calls)
+	}
+	session.EventSkip(1).WithField("body", lazy).Info("enabled")
+	if calls != 2 {
+		t.Fatalf("valuers called %d times", calls)
+	}
+	event := handler2.events[0]
+	if event.Fields["body"] != "computed" || event.Session["lazy"] != "computed" {
+		t.Errorf("unexpected event: %v %v", event.Fields, event.Session)
+	}
+	if session["lazy"] != lazy || event.sessionID() != sessionID(session) {
+		t.Error("original session changed or session identity lost")
+	}
+	fields := event.Fieldify("")
+	if fields["body"] != "computed" || calls != 2 {
+		t.Errorf("unexpected fields %v after %d calls", fields, calls)
+	}
+}
+
+func TestLazyPanic(t *testing.T) {
+	event := newEvent(1, nil)
+	event.Fields["broken"] = Lazy(func() interface{} {
+		panic("boom")
+	})
+	event.Fields["nested"] = Lazy(func() interface{} {
+		return Lazy(func() interface{} { return 1 })
+	})
+	event.resolveValuers()
+	content, err := json.Marshal(event.Fieldify(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `"broken":"LogValue panic: boom"`) || event.Fields["nested"] != 1 {
+		t.Errorf("unexpected content: %s", content)
+	}
+	if text := JoinFields(map[string]interface{}{"lazy": Lazy(func() interface{} { return 2 })}, "=", " ", false); text != "lazy=2" {
+		t.Errorf("unexpected joined fields: %s", text)
+	}
+}
+
+func TestLazyGlobal(t *testing.T) {
+	defer func(fields map[string]interface{}, order []string, valuers bool) {
+		globalFields, globalOrder, globalValuers = fields, order, valuers
+	}(globalFields, globalOrder, globalValuers)
+	globalFields, globalOrder = make(map[string]interface{}), nil
+	receiver := new(receiveHandler)
+	defer SetHandlers(SetHandlers(map[string][]Handler{
+		infoLevel: {receiver, new(receiveHandler)},
+	}))
+	calls := 0
+	WithField("lazy", Lazy(func() interface{} {
+		calls++
+		return "computed"
+	}))
+	NewSession().EventSkip(1).Info("global")
+	event := receiver.events[0]
+	event.Fieldify("")
+	event.RangeFields(func(key string, value interface{}) bool { return true })
+	if value, _ := event.Field("lazy"); value != "computed" || calls != 1 {
+		t.Errorf("unexpected global field %v after %d calls", value, calls)
+	}
+	if text := joinOrderedFields(event.globalView(), globalOrder, nil, "=", " ", false); text != `lazy="computed"` || calls != 1 {
+		t.Errorf("unexpected joined globals %s after %d calls", text, calls)
+	}
+}
@@ -4,10 +4,24 @@ import (
 	"os"
 )
 
+// Writer write formatted events, content may be kept after Write returns
 type Writer interface {
 	Write([]byte) error
 }
 
+// TransientWriter is implemented by writers which do not keep content after
+// Write returns, handlers give them reused buffers, and copies of content to
+// other writers.
+type TransientWriter interface {
+	Writer
+	Transient() bool
+}
+
+func isTransientWriter(writer Writer) bool {
+	transient, ok := writer.(TransientWriter)
+	return ok && transient.Transient()
+}
+
 type FileWriter struct {
 	File *os.File
 }
@@ -21,6 +35,17 @@ func (writer FileWriter) Write(content []byte) error {
 	return err
 }
 
+// Transient return true as content is written to the file before Write returns
+func (writer FileWriter) Transient() bool {
+	return true
+}
+
+// Probe check the file is still usable
+func (writer FileWriter) Probe() error {
+	_, err := writer.File.Stat()
+	return err
+}
+
 var (
 	StdoutWriter = &FileWriter{File: os.Stdout}
 	StderrWriter = &FileWriter{File: os.Stderr}
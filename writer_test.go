@@ -43,3 +43,30 @@ func TestFileWriter(t *testing.T) {
 		t.Error("unexpected file content:", string(content))
 	}
 }
+
+// keepingWriter keep the content given to Write
+type keepingWriter struct {
+	contents [][]byte
+}
+
+func (writer *keepingWriter) Write(content []byte) error {
+	writer.contents = append(writer.contents, content)
+	return nil
+}
+
+func TestKeepingWriter(t *testing.T) {
+	writer := new(keepingWriter)
+	handler := &JsonHandler{Writer: writer}
+	for _, message := range []string{"first", "second"} {
+		event := newEvent(1, nil)
+		event.Level = infoLevel
+		event.Message = message
+		handler.Handle(event)
+	}
+	if len(writer.contents) != 2 || !bytes.Contains(writer.contents[0], []byte(`"message":"first"`)) {
+		t.Errorf("kept content changed: %q", writer.contents)
+	}
+	if !isTransientWriter(StdoutWriter) || !isTransientWriter(&TimeRotatedFileWriter{}) || isTransientWriter(writer) {
+		t.Error("unexpected transient writers")
+	}
+}